@@ -0,0 +1,131 @@
+package stdbtree
+
+import "math"
+
+// bloomFalsePositiveRate is the false positive rate a bloomFilter is sized
+// for, given the no. of items it is expected to hold.
+const bloomFalsePositiveRate = 0.01
+
+// bloomFilter is a Bloom filter over 64 bit hashes. It answers whether a hash
+// may have been added, with no false negatives.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64 // no. of bits
+	k    int    // no. of bit positions set per hash
+}
+
+func newBloomFilter(expectedN int) *bloomFilter {
+	if expectedN < 1 {
+		expectedN = 1
+	}
+	// the standard optimal sizing: m = -n ln p / (ln 2)^2, k = m/n ln 2
+	m := uint64(math.Ceil(-float64(expectedN) * math.Log(bloomFalsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := int(math.Round(float64(m) / float64(expectedN) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// positions calls fn with each of the k bit positions for h, derived from h
+// and a remix of it by double hashing.
+func (f *bloomFilter) positions(h uint64, fn func(pos uint64) bool) {
+	h2 := mix64(h) | 1
+	for i := 0; i < f.k; i++ {
+		if !fn((h + uint64(i)*h2) % f.m) {
+			return
+		}
+	}
+}
+
+func (f *bloomFilter) add(h uint64) {
+	f.positions(h, func(pos uint64) bool {
+		f.bits[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+}
+
+// mayContain reports false only if h has definitely not been added.
+func (f *bloomFilter) mayContain(h uint64) bool {
+	found := true
+	f.positions(h, func(pos uint64) bool {
+		found = f.bits[pos/64]&(1<<(pos%64)) != 0
+		return found
+	})
+	return found
+}
+
+func (f *bloomFilter) reset() {
+	for i := range f.bits {
+		f.bits[i] = 0
+	}
+}
+
+func (f *bloomFilter) copy() *bloomFilter {
+	c := *f
+	c.bits = append([]uint64(nil), f.bits...)
+	return &c
+}
+
+// mix64 is the splitmix64 finalizer, it scrambles the bits of x.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// NewBTreeWithBloom creates an empty BTree with minimum degree t that keeps a
+// Bloom filter of its items, letting Search and Contains turn away most
+// lookups of absent items in O(1) without descending the tree. The filter is
+// sized for expectedN items at a 1% false positive rate, which degrades
+// gracefully if more are inserted. hash must return the same value for items
+// that compare equal.
+//
+// A Bloom filter cannot forget an item, so deleted items keep passing the
+// filter and cost a full descent to look up. Compact rebuilds the filter from
+// the items actually present.
+func NewBTreeWithBloom(t int, expectedN int, hash func(item Item) uint64) *BTree {
+	b := NewBTree(t)
+	b.bloom = newBloomFilter(expectedN)
+	b.hash = hash
+	return b
+}
+
+// bloomAdd records item in the tree's Bloom filter, if it has one.
+func (b *BTree) bloomAdd(item Item) {
+	if b.bloom != nil {
+		b.bloom.add(b.hash(item))
+	}
+}
+
+// bloomMiss reports whether the tree's Bloom filter rules out item. The
+// sentinels are never in the tree and are not passed to hash.
+func (b *BTree) bloomMiss(item Item) bool {
+	if b.bloom == nil {
+		return false
+	}
+	if _, ok := item.(infinity); ok {
+		return true
+	}
+	return !b.bloom.mayContain(b.hash(item))
+}
+
+// rebuildBloom refills the tree's Bloom filter, if it has one, from the items
+// currently in the tree.
+func (b *BTree) rebuildBloom() {
+	if b.bloom == nil {
+		return
+	}
+	b.bloom.reset()
+	b.ForEach(func(item Item) bool {
+		b.bloom.add(b.hash(item))
+		return true
+	})
+}
@@ -0,0 +1,90 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hashNumItem(item Item) uint64 {
+	return mix64(uint64(item.(numItem)))
+}
+
+func TestBloomBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTreeWithBloom(T, N, hashNumItem)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+
+	// no false negatives, and few enough false positives
+	var falsePositives int
+	for i := 0; i < N; i++ {
+		require.True(t, b.Contains(numItem(2*i)), testInfo)
+		require.Nil(t, b.Search(numItem(2*i+1)), testInfo)
+		if b.bloom.mayContain(hashNumItem(numItem(2*i + 1))) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, N/20, testInfo)
+
+	// every way of adding items goes through the filter
+	b.GetOrInsert(numItem(-1))
+	b.InsertMany([]Item{numItem(-3), numItem(-5)})
+	for _, i := range []int{-1, -3, -5} {
+		require.True(t, b.Contains(numItem(i)), testInfo)
+	}
+	empty := NewBTreeWithBloom(T, N, hashNumItem)
+	empty.InsertMany([]Item{numItem(1), numItem(3)})
+	require.True(t, empty.Contains(numItem(3)), testInfo)
+
+	// deleted items are only forgotten by Compact
+	for i := 0; i < N; i++ {
+		b.Delete(numItem(2 * i))
+	}
+	require.False(t, b.Contains(numItem(0)), testInfo)
+	require.True(t, b.bloom.mayContain(hashNumItem(numItem(0))), testInfo)
+	b.Compact()
+	require.False(t, b.bloom.mayContain(hashNumItem(numItem(0))), testInfo)
+	require.True(t, b.Contains(numItem(-3)), testInfo)
+
+	// clones have their own filter
+	c := b.Clone()
+	c.Insert(numItem(1000))
+	require.True(t, c.Contains(numItem(1000)), testInfo)
+	require.False(t, b.bloom.mayContain(hashNumItem(numItem(1000))), testInfo)
+
+	b.Clear()
+	require.False(t, b.Contains(numItem(-3)), testInfo)
+	require.False(t, b.bloom.mayContain(hashNumItem(numItem(-3))), testInfo)
+}
+
+// lookups that are almost all misses, which the filter mostly turns away
+func benchmarkSearchMiss(b *testing.B, tree *BTree) {
+	N := 100000
+	for _, i := range rand.Perm(N) {
+		tree.Insert(numItem(2 * i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(numItem(2*(i%N) + 1))
+	}
+}
+
+func BenchmarkSearchMiss(b *testing.B) {
+	benchmarkSearchMiss(b, NewBTree(16))
+}
+
+func BenchmarkSearchMissBloom(b *testing.B) {
+	benchmarkSearchMiss(b, NewBTreeWithBloom(16, 100000, hashNumItem))
+}
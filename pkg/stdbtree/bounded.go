@@ -0,0 +1,63 @@
+package stdbtree
+
+// BoundedBTree is a BTree capped at a fixed number of items. Once full, every
+// insert of a new item evicts either the largest or the smallest item
+// so that Len never exceeds the capacity, which makes it suitable as an ordered
+// cache of the smallest or largest items seen.
+type BoundedBTree struct {
+	tree     *BTree
+	capacity int
+	evictMax bool
+}
+
+// NewBoundedBTree creates an empty BoundedBTree with minimum degree t holding
+// at most capacity items. If evictMax is set the largest item is evicted when
+// the tree overflows, keeping the smallest items, otherwise the smallest item
+// is evicted. It panics if capacity is less than 1, see NewBTree for the
+// constraints on t.
+func NewBoundedBTree(t, capacity int, evictMax bool) *BoundedBTree {
+	if capacity < 1 {
+		panic("invalid capacity for bounded btree, capacity must be >= 1")
+	}
+	return &BoundedBTree{
+		tree:     NewBTree(t),
+		capacity: capacity,
+		evictMax: evictMax,
+	}
+}
+
+// Insert adds item to the tree, replacing and returning any equal item as prev.
+// If this overflows the capacity the boundary item is removed and returned as
+// evicted, which may be item itself if it lies beyond the kept range.
+func (b *BoundedBTree) Insert(item Item) (prev, evicted Item) {
+	prev = b.tree.Insert(item)
+	if b.tree.len > b.capacity {
+		if b.evictMax {
+			evicted = b.tree.DeleteMax()
+		} else {
+			evicted = b.tree.DeleteMin()
+		}
+	}
+	return prev, evicted
+}
+
+// Search returns the stored item equal to item, or nil if there is none.
+func (b *BoundedBTree) Search(item Item) Item {
+	return b.tree.Search(item)
+}
+
+// Delete removes the item equal to item from the tree and returns it, or
+// returns nil if there is none.
+func (b *BoundedBTree) Delete(item Item) Item {
+	return b.tree.Delete(item)
+}
+
+// Len returns the number of items in the tree, at most the capacity.
+func (b *BoundedBTree) Len() int {
+	return b.tree.Len()
+}
+
+// ToSlice returns every item in the tree in ascending order.
+func (b *BoundedBTree) ToSlice() []Item {
+	return b.tree.ToSlice()
+}
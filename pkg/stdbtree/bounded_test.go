@@ -0,0 +1,49 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundedBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	capacity, K := 100, 50
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.Panics(t, func() { NewBoundedBTree(T, 0, true) })
+
+	for _, evictMax := range []bool{true, false} {
+		b := NewBoundedBTree(T, capacity, evictMax)
+		var evictions int
+		for _, i := range rand.Perm(capacity + K) {
+			_, evicted := b.Insert(numItem(i))
+			if evicted != nil {
+				evictions++
+			}
+			require.LessOrEqual(t, b.Len(), capacity, testInfo)
+		}
+		require.Equal(t, K, evictions, testInfo)
+		require.NoError(t, checkInvariances(b.tree, capacity), testInfo)
+
+		// survivors are the smallest or largest capacity items
+		first := 0
+		if !evictMax {
+			first = K
+		}
+		items := b.ToSlice()
+		for i, item := range items {
+			require.Equal(t, numItem(first+i), item, testInfo)
+		}
+
+		// replacing an existing item never evicts
+		prev, evicted := b.Insert(items[0])
+		require.NotNil(t, prev, testInfo)
+		require.Nil(t, evicted, testInfo)
+	}
+}
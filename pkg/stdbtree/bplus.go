@@ -0,0 +1,390 @@
+package stdbtree
+
+import "fmt"
+
+// bplusNode is a node of a BPlusTree. In a leaf items holds the stored items
+// and next links to the leaf holding the items that follow. In an internal
+// node items holds separators, copies of keys that only route searches:
+// everything in children[i] is less than items[i], everything in
+// children[i+1] greater than or equal to it.
+type bplusNode struct {
+	isLeaf   bool
+	n        int // tracks no. of items in a node
+	items    []Item
+	children []*bplusNode
+	next     *bplusNode // next leaf in order, nil for internal nodes
+}
+
+func newBPlusNode(t int, isLeaf bool) *bplusNode {
+	items := make([]Item, 2*t-1)
+	var children []*bplusNode = nil
+	if !isLeaf { // if is internal
+		children = make([]*bplusNode, 2*t)
+	}
+	return &bplusNode{
+		isLeaf:   isLeaf,
+		items:    items,
+		children: children,
+	}
+}
+
+// find returns the index of the first item in n that is not less than key,
+// and whether the item at that index is equal to key, see node.find.
+func (n *bplusNode) find(key Item) (i int, found bool) {
+	lo, hi := 0, n.n
+	for lo < hi {
+		h := int(uint(lo+hi) >> 1)
+		switch key.Compare(n.items[h]) {
+		case greaterThan:
+			lo = h + 1
+		case equal:
+			return h, true
+		case lessThan:
+			hi = h
+		}
+	}
+	return lo, false
+}
+
+// route returns the index of the child of internal node n whose subtree may
+// hold key.
+func (n *bplusNode) route(key Item) int {
+	i, found := n.find(key)
+	if found {
+		// keys equal to a separator live to its right
+		i++
+	}
+	return i
+}
+
+func (n *bplusNode) insertAt(i int, item Item) {
+	copy(n.items[i+1:], n.items[i:n.n])
+	n.items[i] = item
+	n.n++
+}
+
+func (n *bplusNode) removeAt(i int) (removed Item) {
+	removed = n.items[i]
+	copy(n.items[i:], n.items[i+1:n.n])
+	n.n--
+	n.items[n.n] = nil
+	return removed
+}
+
+// splitChild splits the full ith child y of n in two. A leaf keeps its lower
+// t-1 items and the new leaf z, linked in after it, gets the upper t with a
+// copy of the first one going up to n as the separator. An internal node is
+// split as in CLRS, its median moving up to n.
+func (n *bplusNode) splitChild(t int, i int) {
+	y := n.children[i]
+	z := newBPlusNode(t, y.isLeaf)
+	var sep Item
+	if y.isLeaf {
+		copy(z.items, y.items[t-1:y.n])
+		z.n = t
+		sep = z.items[0]
+		z.next, y.next = y.next, z
+	} else {
+		copy(z.items, y.items[t:y.n])
+		copy(z.children, y.children[t:y.n+1])
+		z.n = t - 1
+		sep = y.items[t-1]
+		for j := t; j <= y.n; j++ {
+			y.children[j] = nil
+		}
+	}
+	for j := t - 1; j < y.n; j++ {
+		y.items[j] = nil
+	}
+	y.n = t - 1
+
+	n.insertAt(i, sep)
+	copy(n.children[i+2:], n.children[i+1:n.n])
+	n.children[i+1] = z
+}
+
+// insert inserts newItem into the subtree rooted at n, splitting full nodes on
+// the way down. If an equal item is already present it is replaced and
+// returned.
+func (n *bplusNode) insert(t int, newItem Item) (prev Item) {
+	for !n.isLeaf {
+		i := n.route(newItem)
+		if n.children[i].n == 2*t-1 {
+			n.splitChild(t, i)
+			if newItem.Compare(n.items[i]) != lessThan {
+				// go to newly upped right child
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+	i, found := n.find(newItem)
+	if found {
+		prev, n.items[i] = n.items[i], newItem
+		return prev
+	}
+	n.insertAt(i, newItem)
+	return nil
+}
+
+// remove deletes the item equal to key from the subtree rooted at n, topping
+// up every child to at least t items before descending into it so that a leaf
+// can always give up an item, see node.remove.
+func (n *bplusNode) remove(t int, key Item) (removed Item) {
+	for !n.isLeaf {
+		i := n.route(key)
+		if n.children[i].n == t-1 {
+			i = n.fillChild(t, i)
+		}
+		n = n.children[i]
+	}
+	i, found := n.find(key)
+	if !found {
+		return nil
+	}
+	return n.removeAt(i)
+}
+
+// fillChild ensures that the ith child of n has at least t items by either
+// borrowing an item from an adjacent sibling or merging it with one. It
+// returns the index of the child that now holds the items of the ith child.
+func (n *bplusNode) fillChild(t int, i int) int {
+	switch {
+	case i > 0 && n.children[i-1].n >= t:
+		c, left := n.children[i], n.children[i-1]
+		if c.isLeaf {
+			// move left's last item over, it becomes the new separator
+			c.insertAt(0, left.removeAt(left.n-1))
+			n.items[i-1] = c.items[0]
+		} else {
+			// rotate through the separator as in CLRS
+			c.insertAt(0, n.items[i-1])
+			copy(c.children[1:], c.children[:c.n])
+			c.children[0] = left.children[left.n]
+			left.children[left.n] = nil
+			n.items[i-1] = left.removeAt(left.n - 1)
+		}
+	case i < n.n && n.children[i+1].n >= t:
+		c, right := n.children[i], n.children[i+1]
+		if c.isLeaf {
+			// move right's first item over, its new first item becomes the
+			// separator
+			c.insertAt(c.n, right.removeAt(0))
+			n.items[i] = right.items[0]
+		} else {
+			c.insertAt(c.n, n.items[i])
+			c.children[c.n] = right.children[0]
+			copy(right.children, right.children[1:right.n+1])
+			right.children[right.n] = nil
+			n.items[i] = right.removeAt(0)
+		}
+	default:
+		if i == n.n { // rightmost child has no right sibling, merge with left
+			i--
+		}
+		n.mergeChildren(i)
+	}
+	return i
+}
+
+// mergeChildren merges the (i+1)th child of n into the ith one. Both must have
+// t-1 items. Merging leaves drops the separator items[i], merging internal
+// nodes pulls it down between the two halves.
+func (n *bplusNode) mergeChildren(i int) {
+	y, z := n.children[i], n.children[i+1]
+	if y.isLeaf {
+		copy(y.items[y.n:], z.items[:z.n])
+		y.n += z.n
+		y.next = z.next
+	} else {
+		y.items[y.n] = n.items[i]
+		copy(y.items[y.n+1:], z.items[:z.n])
+		copy(y.children[y.n+1:], z.children[:z.n+1])
+		y.n += z.n + 1
+	}
+
+	// remove separator and z from n
+	n.removeAt(i)
+	copy(n.children[i+1:], n.children[i+2:n.n+2])
+	n.children[n.n+1] = nil
+}
+
+// BPlusTree is an ordered collection of Items organised as a B+ tree: unlike
+// BTree every item is stored in a leaf, internal nodes only holding copies of
+// keys to route searches, and the leaves are linked in order. A range scan
+// therefore descends the tree once and then simply walks the leaves. The zero
+// value is not usable, use NewBPlusTree to create one.
+type BPlusTree struct {
+	root *bplusNode
+	t    int
+	len  int
+}
+
+// NewBPlusTree creates an empty BPlusTree with minimum degree t, every node
+// holding between t-1 and 2t-1 items (or separators) except for the root. t
+// must be >= 2, NewBPlusTree panics otherwise.
+func NewBPlusTree(t int) *BPlusTree {
+	if err := checkDegree(t); err != nil {
+		panic(err)
+	}
+	return &BPlusTree{
+		t:    t,
+		root: newBPlusNode(t, true),
+	}
+}
+
+// leaf returns the leaf whose range covers item.
+func (b *BPlusTree) leaf(item Item) *bplusNode {
+	n := b.root
+	for !n.isLeaf {
+		n = n.children[n.route(item)]
+	}
+	return n
+}
+
+// Search returns the stored item equal to item, or nil if there is none.
+func (b *BPlusTree) Search(item Item) Item {
+	n := b.leaf(item)
+	if i, found := n.find(item); found {
+		return n.items[i]
+	}
+	return nil
+}
+
+// Insert adds item to the tree. If an equal item is already present it is
+// replaced and returned, otherwise nil is returned.
+func (b *BPlusTree) Insert(item Item) (prev Item) {
+	if b.root.n == 2*b.t-1 {
+		oldRoot := b.root
+		b.root = newBPlusNode(b.t, false)
+		b.root.children[0] = oldRoot
+		b.root.splitChild(b.t, 0)
+	}
+	prev = b.root.insert(b.t, item)
+	if prev == nil {
+		b.len++
+	}
+	return
+}
+
+// Delete removes the item equal to item from the tree and returns it, or
+// returns nil if there is none.
+func (b *BPlusTree) Delete(item Item) (removed Item) {
+	if b.len == 0 {
+		return nil
+	}
+	removed = b.root.remove(b.t, item)
+	if b.root.n == 0 && !b.root.isLeaf {
+		// root emptied out after a merge, shrink the tree height
+		b.root = b.root.children[0]
+	}
+	if removed != nil {
+		b.len--
+	}
+	return
+}
+
+// RangeScan returns, in ascending order, every item x in the tree with
+// lo <= x <= hi. Either bound may be nil to leave that end of the range open.
+func (b *BPlusTree) RangeScan(lo, hi Item) []Item {
+	var items []Item
+	var n *bplusNode
+	var i int
+	if lo == nil {
+		for n = b.root; !n.isLeaf; n = n.children[0] {
+		}
+	} else {
+		n = b.leaf(lo)
+		i, _ = n.find(lo)
+	}
+	for ; n != nil; n, i = n.next, 0 {
+		for ; i < n.n; i++ {
+			if hi != nil && hi.Compare(n.items[i]) == lessThan {
+				return items
+			}
+			items = append(items, n.items[i])
+		}
+	}
+	return items
+}
+
+// Len returns the number of items in the tree.
+func (b *BPlusTree) Len() int {
+	return b.len
+}
+
+// Validate checks that the tree satisfies every B+ tree invariant: the leaves
+// hold the items in strictly ascending order and are linked in that order,
+// every separator bounds the subtrees on either side of it, all nodes but the
+// root hold between t-1 and 2t-1 items, all leaves are at the same depth and
+// len matches the number of items. It returns an error describing the first
+// violation found, wrapping one of the same Err values as BTree.Validate, or
+// nil.
+func (b *BPlusTree) Validate() error {
+	if b.root == nil {
+		return fmt.Errorf("%w: no root node", ErrMalformed)
+	}
+	var leaves []*bplusNode
+	leafDepth := -1
+	// check walks the subtree rooted at n whose items must all be in
+	// [lo, hi), a nil bound being open
+	var check func(n *bplusNode, lo, hi Item, depth int) error
+	check = func(n *bplusNode, lo, hi Item, depth int) error {
+		if n.n < 0 || n.n > 2*b.t-1 || (n != b.root && n.n < b.t-1) {
+			return fmt.Errorf("%w: %d", ErrBadNodeCount, n.n)
+		}
+		for i := 0; i < n.n; i++ {
+			if i > 0 && n.items[i-1].Compare(n.items[i]) != lessThan {
+				return fmt.Errorf("%w\n: %v comes before %v", ErrOutOfOrder, n.items[i-1], n.items[i])
+			}
+			if (lo != nil && n.items[i].Compare(lo) == lessThan) || (hi != nil && n.items[i].Compare(hi) != lessThan) {
+				return fmt.Errorf("%w: item %v is outside the range of its separators [%v, %v)", ErrOutOfOrder, n.items[i], lo, hi)
+			}
+		}
+		if n.isLeaf {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if depth != leafDepth {
+				return fmt.Errorf("%w: %d vs %d", ErrUnequalLeafHeight, depth, leafDepth)
+			}
+			leaves = append(leaves, n)
+			return nil
+		}
+		for i := 0; i <= n.n; i++ {
+			clo, chi := lo, hi
+			if i > 0 {
+				clo = n.items[i-1]
+			}
+			if i < n.n {
+				chi = n.items[i]
+			}
+			if err := check(n.children[i], clo, chi, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(b.root, nil, nil, 0); err != nil {
+		return err
+	}
+
+	// the leaf chain visits the leaves in the same order as the tree
+	var count int
+	for i, n := range leaves {
+		var next *bplusNode
+		if i+1 < len(leaves) {
+			next = leaves[i+1]
+		}
+		if n.next != next {
+			return fmt.Errorf("%w: leaf %d is not linked to the leaf after it", ErrMalformed, i)
+		}
+		if i > 0 && n.n == 0 {
+			return fmt.Errorf("%w: leaf %d is empty", ErrBadNodeCount, i)
+		}
+		count += n.n
+	}
+	if count != b.len {
+		return fmt.Errorf("%w: btree has len %d but holds %d items", ErrBadSize, b.len, count)
+	}
+	return nil
+}
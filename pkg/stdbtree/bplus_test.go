@@ -0,0 +1,92 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// checkBPlusInvariances is checkInvariances for a BPlusTree.
+func checkBPlusInvariances(b *BPlusTree, expectedLen int) error {
+	if b.Len() != expectedLen {
+		return fmt.Errorf("Expected btree to have len %d, instead has len %d", expectedLen, b.Len())
+	}
+	return b.Validate()
+}
+
+func TestBPlusTree(t *testing.T) {
+	require.Panics(t, func() {
+		NewBPlusTree(1)
+	})
+
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBPlusTree(T)
+	require.NoError(t, checkBPlusInvariances(b, 0), testInfo)
+	require.Nil(t, b.Delete(numItem(0)), testInfo)
+	require.Empty(t, b.RangeScan(nil, nil), testInfo)
+
+	present := make(map[numItem]bool)
+	for i := 0; i < 20*N; i++ {
+		num := numItem(rand.Intn(N))
+		if rand.Intn(3) > 0 {
+			prev := b.Insert(num)
+			require.Equal(t, present[num], prev != nil, testInfo)
+			present[num] = true
+		} else {
+			removed := b.Delete(num)
+			require.Equal(t, present[num], removed != nil, testInfo)
+			delete(present, num)
+		}
+		require.NoError(t, checkBPlusInvariances(b, len(present)), testInfo)
+	}
+	for i := 0; i < N; i++ {
+		require.Equal(t, present[numItem(i)], b.Search(numItem(i)) != nil, testInfo)
+	}
+
+	var sorted []Item
+	for num := range present {
+		sorted = append(sorted, num)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Compare(sorted[j]) == lessThan })
+	require.Equal(t, sorted, b.RangeScan(nil, nil), testInfo)
+	for i := 0; i < 50; i++ {
+		lo, hi := numItem(rand.Intn(N+10)-5), numItem(rand.Intn(N+10)-5)
+		var expected []Item
+		for _, item := range sorted {
+			if lo <= item.(numItem) && item.(numItem) <= hi {
+				expected = append(expected, item)
+			}
+		}
+		require.Equal(t, expected, b.RangeScan(lo, hi), "%s [%d, %d]", testInfo, lo, hi)
+	}
+	var expected []Item
+	for _, item := range sorted {
+		if item.(numItem) <= numItem(N/2) {
+			expected = append(expected, item)
+		}
+	}
+	require.Equal(t, expected, b.RangeScan(nil, numItem(N/2)), testInfo)
+
+	// every item lives in a leaf
+	for _, item := range sorted {
+		n := b.leaf(item)
+		_, found := n.find(item)
+		require.True(t, found, testInfo)
+	}
+
+	// delete everything, tree should shrink back down to a single empty leaf
+	for i, item := range sorted {
+		require.NotNil(t, b.Delete(item), testInfo)
+		require.NoError(t, checkBPlusInvariances(b, len(sorted)-i-1), testInfo)
+	}
+	require.True(t, b.root.isLeaf, testInfo)
+}
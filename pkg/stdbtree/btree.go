@@ -1,17 +1,26 @@
+// Package stdbtree implements the B-tree described in CLRS (Introduction to
+// Algorithms, chapter 18).
 package stdbtree
 
+import "fmt"
+
 const greaterThan = 1
 const equal = 0
 const lessThan = -1
 
-type item interface {
-	compare(item) int
+// Item is a value that can be stored in a BTree. Compare must return -1, 0 or
+// 1 according to whether the receiver is less than, equal to or greater than
+// the argument; items that compare equal are treated as the same key.
+type Item interface {
+	Compare(Item) int
 }
 
 type node struct {
 	isLeaf   bool
-	n        int // tracks no. of items in a node
-	items    []item
+	n        int    // tracks no. of items in a node
+	size     int    // tracks no. of items in the subtree rooted at this node
+	gen      uint64 // generation of the tree that owns, and may modify, this node
+	items    []Item
 	children []*node
 }
 
@@ -35,7 +44,7 @@ type node struct {
 // }
 
 func newNode(t int, isLeaf bool) *node {
-	items := make([]item, 2*t-1)
+	items := make([]Item, 2*t-1)
 	var children []*node = nil
 	if !isLeaf { // if is internal
 		children = make([]*node, 2*t)
@@ -47,94 +56,181 @@ func newNode(t int, isLeaf bool) *node {
 	}
 }
 
-func (n *node) search(item item) item {
-	var i int
-loop:
-	for i = 0; i < n.n; i++ {
-		switch item.compare(n.items[i]) {
-		case equal:
+// search returns the item in the subtree rooted at n equal to item, or nil if
+// there is none. It does not trust the shape of the tree: a nil node, an item
+// count out of range of the items slice or a missing child end the search
+// rather than panicking, see also Validate.
+func (n *node) search(cmp func(a, b Item) int, item Item) Item {
+	for n != nil && n.n >= 0 && n.n <= len(n.items) {
+		// items[:i] < item, so item is either items[i] or lies in
+		// children[i], which is the last child if item is greater than
+		// every item in n
+		i, found := n.find(cmp, item)
+		if found {
 			return n.items[i]
-		case lessThan:
-			break loop
 		}
+		if n.isLeaf || i >= len(n.children) {
+			return nil
+		}
+		n = n.children[i]
 	}
-	if n.isLeaf {
-		return nil
+	return nil
+}
+
+// floor returns the largest item in the subtree rooted at n that is less than
+// or equal to item, or nil if there is none.
+func (n *node) floor(cmp func(a, b Item) int, item Item) (best Item) {
+	for {
+		i, found := n.find(cmp, item)
+		if found {
+			return n.items[i]
+		}
+		if i > 0 {
+			// items[i-1] < item, anything closer lies in children[i]
+			best = n.items[i-1]
+		}
+		if n.isLeaf {
+			return best
+		}
+		n = n.children[i]
 	}
-	return n.children[i].search(item)
+}
 
+// ceiling returns the smallest item in the subtree rooted at n that is greater
+// than or equal to item, or nil if there is none.
+func (n *node) ceiling(cmp func(a, b Item) int, item Item) (best Item) {
+	for {
+		i, found := n.find(cmp, item)
+		if found {
+			return n.items[i]
+		}
+		if i < n.n {
+			// items[i] > item, anything closer lies in children[i]
+			best = n.items[i]
+		}
+		if n.isLeaf {
+			return best
+		}
+		n = n.children[i]
+	}
 }
 
-func (n *node) insertLeaf(newItem item) (prev item) {
-	var i int
-loop:
-	for i = 0; i < n.n; i++ {
-		curr := n.items[i]
-		switch newItem.compare(curr) {
-		case equal:
-			prev = curr
-			break loop
-		case lessThan:
-			copy(n.items[i+1:], n.items[i:])
-			break loop
+// predecessor returns the largest item in the subtree rooted at n that is
+// strictly less than item, or nil if there is none.
+func (n *node) predecessor(cmp func(a, b Item) int, item Item) (best Item) {
+	for {
+		// items[:i] < item <= items[i:], so anything closer than items[i-1]
+		// lies in children[i]. This also steps into the left subtree of a
+		// separator equal to item.
+		i, _ := n.find(cmp, item)
+		if i > 0 {
+			best = n.items[i-1]
+		}
+		if n.isLeaf {
+			return best
 		}
+		n = n.children[i]
 	}
-	n.items[i] = newItem
-	if prev == nil { // i.e. is fresh insert
-		n.n++
+}
+
+// successor returns the smallest item in the subtree rooted at n that is
+// strictly greater than item, or nil if there is none.
+func (n *node) successor(cmp func(a, b Item) int, item Item) (best Item) {
+	for {
+		// items[:i] <= item < items[i:], so anything closer than items[i]
+		// lies in children[i]. This also steps into the right subtree of a
+		// separator equal to item.
+		i := n.upperBound(cmp, item)
+		if i < n.n {
+			best = n.items[i]
+		}
+		if n.isLeaf {
+			return best
+		}
+		n = n.children[i]
+	}
+}
+
+// insertLeaf inserts newItem into leaf n. If an equal item is already present
+// it is returned, and replaced by newItem only if replace is set.
+func (n *node) insertLeaf(t int, cmp func(a, b Item) int, newItem Item, replace bool) (prev Item) {
+	i, found := n.find(cmp, newItem)
+	if found {
+		prev = n.items[i]
+		if replace {
+			n.items[i] = newItem
+		}
+		return
 	}
+	n.reserve(t, n.n+1)
+	copy(n.items[i+1:], n.items[i:n.n])
+	n.items[i] = newItem
+	n.n++
+	n.size++
 	return
 }
 
-func (n *node) insert(t int, newItem item) (prev item) {
+// insert inserts newItem into the subtree rooted at n, splitting full nodes on
+// the way down. If an equal item is already present it is returned, and
+// replaced by newItem only if replace is set. depth is the no. of nodes
+// visited, n included. If splits is not nil it is incremented for every split.
+func (n *node) insert(t int, p *nodePool, cmp func(a, b Item) int, newItem Item, replace bool, splits *int) (prev Item, depth int) {
 	if n.isLeaf {
-		return n.insertLeaf(newItem)
+		return n.insertLeaf(t, cmp, newItem, replace), 1
 	}
-	var i int
-loop:
-	for i = 0; i < n.n; i++ {
-		curr := n.items[i]
-		switch newItem.compare(curr) {
-		case equal:
-			prev = curr
+	i, found := n.find(cmp, newItem)
+	if found {
+		prev = n.items[i]
+		if replace {
 			n.items[i] = newItem
-			return
-		case lessThan:
-			break loop
 		}
+		return prev, 1
 	}
-	c := n.children[i]
+	c := n.mutableChild(i)
 	if c.n == 2*t-1 {
-		median := n.splitChild(t, i)
-		switch newItem.compare(median) {
+		median := n.splitChild(t, p, i)
+		if splits != nil {
+			*splits++
+		}
+		switch cmp(newItem, median) {
 		case lessThan:
 			// go to left child
 		case equal:
 			// replace
 			prev = median
-			n.items[i] = newItem
-			return
+			if replace {
+				n.items[i] = newItem
+			}
+			return prev, 1
 		case greaterThan:
 			// go to newly upped right child
 			c = n.children[i+1]
 		}
 	}
-	return c.insert(t, newItem)
+	prev, depth = c.insert(t, p, cmp, newItem, replace, splits)
+	if prev == nil {
+		n.size++
+	}
+	return prev, depth + 1
 }
 
-func (n *node) splitChild(t int, i int) (median item) {
+func (n *node) splitChild(t int, p *nodePool, i int) (median Item) {
 	// let y be the ith child of node n.
-	y := n.children[i]
+	y := n.mutableChild(i)
 	median = y.items[t-1]
 
 	// halve y and move the upper half to new node z
-	z := newNode(t, y.isLeaf)
+	z := p.newNode(t, y.isLeaf)
+	z.gen = n.gen
+	z.reserve(t, t-1)
 	copy(z.items, y.items[t:])
 	z.n = t - 1
 	y.n = t - 1
 	if !y.isLeaf { // only internal nodes have children
 		copy(z.children, y.children[t:])
 	}
+	z.size = z.computeSize()
+	y.size -= z.size + 1
 
 	// move median item up to parent (node n)
 	copy(n.items[i+1:], n.items[i:])
@@ -147,43 +243,908 @@ func (n *node) splitChild(t int, i int) (median item) {
 	return median
 }
 
-type btree struct {
-	root *node
-	t    int
-	len  int
+// computeSize returns the no. of items in the subtree rooted at n from n's
+// own items and the sizes of its children.
+func (n *node) computeSize() int {
+	size := n.n
+	if !n.isLeaf {
+		for i := 0; i <= n.n; i++ {
+			size += n.children[i].size
+		}
+	}
+	return size
+}
+
+// childSize returns the size of the ith child of n, 0 for leaves.
+func (n *node) childSize(i int) int {
+	if n.isLeaf {
+		return 0
+	}
+	return n.children[i].size
+}
+
+// copy returns a shallow copy of n owned by generation gen, the children are
+// shared with n.
+func (n *node) copy(gen uint64) *node {
+	c := &node{
+		isLeaf: n.isLeaf,
+		n:      n.n,
+		size:   n.size,
+		gen:    gen,
+		items:  make([]Item, len(n.items)),
+	}
+	copy(c.items, n.items)
+	if !n.isLeaf {
+		c.children = make([]*node, len(n.children))
+		copy(c.children, n.children)
+	}
+	return c
+}
+
+// clone returns a deep copy of the subtree rooted at n owned by generation
+// gen. Items are shared.
+func (n *node) clone(gen uint64) *node {
+	c := n.copy(gen)
+	if !n.isLeaf {
+		for i := 0; i <= n.n; i++ {
+			c.children[i] = n.children[i].clone(gen)
+		}
+	}
+	return c
+}
+
+// reserve makes sure n's items slice has room for k items. Only the leaves of
+// a compact tree start out with less than the 2t-1 items a node can hold;
+// they grow by doubling, capped at 2t-1.
+func (n *node) reserve(t, k int) {
+	if k <= len(n.items) {
+		return
+	}
+	size := 2 * len(n.items)
+	if size < k {
+		size = k
+	}
+	if size > 2*t-1 {
+		size = 2*t - 1
+	}
+	items := make([]Item, size)
+	copy(items, n.items[:n.n])
+	n.items = items
+}
+
+// mutableFor returns n if it is owned by generation gen, otherwise a copy of n
+// that is. A node owned by another generation may be shared with other
+// versions of the tree and must never be modified in place.
+func (n *node) mutableFor(gen uint64) *node {
+	if n.gen == gen {
+		return n
+	}
+	return n.copy(gen)
+}
+
+// mutableChild makes the ith child of n safe to modify by n's generation and
+// returns it. Every write path goes through here before touching a child.
+func (n *node) mutableChild(i int) *node {
+	c := n.children[i].mutableFor(n.gen)
+	n.children[i] = c
+	return c
+}
+
+// min returns the smallest item in the subtree rooted at n.
+func (n *node) min() Item {
+	for !n.isLeaf {
+		n = n.children[0]
+	}
+	if n.n == 0 {
+		return nil
+	}
+	return n.items[0]
+}
+
+// max returns the largest item in the subtree rooted at n.
+func (n *node) max() Item {
+	for !n.isLeaf {
+		n = n.children[n.n]
+	}
+	if n.n == 0 {
+		return nil
+	}
+	return n.items[n.n-1]
+}
+
+// find returns the index of the first item in n that is not less than key,
+// and whether the item at that index is equal to key. It binary searches the
+// items so a node costs O(log t) comparisons rather than O(t).
+func (n *node) find(cmp func(a, b Item) int, key Item) (i int, found bool) {
+	lo, hi := 0, n.n
+	for lo < hi {
+		// invariant: items[:lo] < key <= items[hi:]
+		h := int(uint(lo+hi) >> 1)
+		switch cmp(key, n.items[h]) {
+		case greaterThan:
+			lo = h + 1
+		case equal:
+			// keep looking left for the first of several equal items
+			found = true
+			hi = h
+		case lessThan:
+			hi = h
+		}
+	}
+	return lo, found
+}
+
+// upperBound returns the index of the first item in n that is greater than key.
+func (n *node) upperBound(cmp func(a, b Item) int, key Item) (i int) {
+	lo, hi := 0, n.n
+	for lo < hi {
+		// invariant: items[:lo] <= key < items[hi:]
+		h := int(uint(lo+hi) >> 1)
+		if cmp(key, n.items[h]) == lessThan {
+			hi = h
+		} else {
+			lo = h + 1
+		}
+	}
+	return lo
+}
+
+// insertMulti is like insert except that it never replaces an existing item,
+// newItem is placed after any items equal to it.
+func (n *node) insertMulti(t int, p *nodePool, cmp func(a, b Item) int, newItem Item, splits *int) (depth int) {
+	for {
+		depth++
+		i := n.upperBound(cmp, newItem)
+		n.size++
+		if n.isLeaf {
+			n.reserve(t, n.n+1)
+			copy(n.items[i+1:], n.items[i:n.n])
+			n.items[i] = newItem
+			n.n++
+			return depth
+		}
+		if n.children[i].n == 2*t-1 {
+			median := n.splitChild(t, p, i)
+			if splits != nil {
+				*splits++
+			}
+			if cmp(newItem, median) != lessThan {
+				// go to newly upped right child
+				i++
+			}
+		}
+		n = n.mutableChild(i)
+	}
+}
+
+// removeAt removes the item at index i from n, shifting the items after it
+// left. It does not touch children.
+func (n *node) removeAt(i int) (removed Item) {
+	removed = n.items[i]
+	copy(n.items[i:], n.items[i+1:n.n])
+	n.n--
+	n.items[n.n] = nil
+	return removed
+}
+
+type toRemove int
+
+const (
+	removeItem toRemove = iota // remove the given item
+	removeMin                  // remove the smallest item in the subtree
+	removeMax                  // remove the largest item in the subtree
+)
+
+// remove deletes an item from the subtree rooted at n following CLRS
+// B-Tree-Delete. It relies on the invariant that n has at least t items
+// whenever it is not the root, which is guaranteed by fixing up a child
+// before descending into it.
+func (n *node) remove(t int, p *nodePool, cmp func(a, b Item) int, key Item, typ toRemove) (removed Item) {
+	removed = n.removeFrom(t, p, cmp, key, typ)
+	if removed != nil {
+		n.size--
+	}
+	return
+}
+
+func (n *node) removeFrom(t int, p *nodePool, cmp func(a, b Item) int, key Item, typ toRemove) (removed Item) {
+	var i int
+	var found bool
+	switch typ {
+	case removeMax:
+		if n.isLeaf {
+			return n.removeAt(n.n - 1)
+		}
+		i = n.n
+	case removeMin:
+		if n.isLeaf {
+			return n.removeAt(0)
+		}
+		i = 0
+	case removeItem:
+		i, found = n.find(cmp, key)
+		if n.isLeaf {
+			// case 1: key is in a leaf, or absent altogether
+			if found {
+				return n.removeAt(i)
+			}
+			return nil
+		}
+	}
+
+	if found {
+		// case 2: key is in internal node n
+		y, z := n.children[i], n.children[i+1]
+		switch {
+		case y.n >= t:
+			// 2a: replace key with its predecessor
+			removed = n.items[i]
+			n.items[i] = n.mutableChild(i).remove(t, p, cmp, nil, removeMax)
+			return removed
+		case z.n >= t:
+			// 2b: replace key with its successor
+			removed = n.items[i]
+			n.items[i] = n.mutableChild(i+1).remove(t, p, cmp, nil, removeMin)
+			return removed
+		default:
+			// 2c: merge key and z into y, then delete key from y
+			n.mergeChildren(t, p, i)
+			return n.children[i].remove(t, p, cmp, key, removeItem)
+		}
+	}
+
+	// case 3: key, if present, is in the subtree rooted at children[i]
+	if n.children[i].n == t-1 {
+		i = n.fillChild(t, p, i)
+	}
+	return n.mutableChild(i).remove(t, p, cmp, key, typ)
+}
+
+// fillChild ensures that the ith child of n has at least t items by either
+// borrowing an item from an adjacent sibling (3a) or merging it with one (3b).
+// It returns the index of the child that now holds the items of the ith child.
+func (n *node) fillChild(t int, p *nodePool, i int) int {
+	switch {
+	case i > 0 && n.children[i-1].n >= t:
+		n.borrowFromLeft(t, i)
+	case i < n.n && n.children[i+1].n >= t:
+		n.borrowFromRight(t, i)
+	default:
+		if i == n.n { // rightmost child has no right sibling, merge with left
+			i--
+		}
+		n.mergeChildren(t, p, i)
+	}
+	return i
+}
+
+// borrowFromLeft moves the separator items[i-1] down into the front of the ith
+// child and moves the last item of the left sibling up to replace it.
+func (n *node) borrowFromLeft(t, i int) {
+	c, left := n.mutableChild(i), n.mutableChild(i-1)
+	c.reserve(t, c.n+1)
+
+	copy(c.items[1:], c.items[:c.n])
+	c.items[0] = n.items[i-1]
+	moved := 1
+	if !c.isLeaf {
+		copy(c.children[1:], c.children[:c.n+1])
+		c.children[0] = left.children[left.n]
+		left.children[left.n] = nil
+		moved += c.children[0].size
+	}
+	c.n++
+	c.size += moved
+	left.size -= moved
+
+	n.items[i-1] = left.items[left.n-1]
+	left.items[left.n-1] = nil
+	left.n--
+}
+
+// borrowFromRight moves the separator items[i] down onto the end of the ith
+// child and moves the first item of the right sibling up to replace it.
+func (n *node) borrowFromRight(t, i int) {
+	c, right := n.mutableChild(i), n.mutableChild(i+1)
+	c.reserve(t, c.n+1)
+
+	c.items[c.n] = n.items[i]
+	moved := 1
+	if !c.isLeaf {
+		c.children[c.n+1] = right.children[0]
+		copy(right.children, right.children[1:right.n+1])
+		right.children[right.n] = nil
+		moved += c.children[c.n+1].size
+	}
+	c.n++
+	c.size += moved
+	right.size -= moved
+
+	n.items[i] = right.items[0]
+	right.removeAt(0)
+}
+
+// mergeChildren merges the separator items[i] and the (i+1)th child into the
+// ith child. Both children must have t-1 items so the result has 2t-1.
+func (n *node) mergeChildren(t int, p *nodePool, i int) {
+	// z is dropped from n so only y needs to be owned by n's generation
+	y, z := n.mutableChild(i), n.children[i+1]
+	y.reserve(t, y.n+z.n+1)
+
+	y.items[y.n] = n.items[i]
+	copy(y.items[y.n+1:], z.items[:z.n])
+	if !y.isLeaf {
+		copy(y.children[y.n+1:], z.children[:z.n+1])
+	}
+	y.n += z.n + 1
+	y.size += z.size + 1
+
+	// remove separator and z from n
+	copy(n.items[i:], n.items[i+1:n.n])
+	copy(n.children[i+1:], n.children[i+2:n.n+1])
+	n.items[n.n-1] = nil
+	n.children[n.n] = nil
+	n.n--
+
+	if z.gen == n.gen { // z may still be in use by another version otherwise
+		p.free(z)
+	}
+}
+
+// BTree is an ordered collection of Items. The zero value is not usable, use
+// NewBTree to create one.
+type BTree struct {
+	root  *node
+	t     int
+	len   int
+	cmp   func(a, b Item) int    // orders the items, see NewBTreeFunc
+	multi bool                   // store equal items side by side rather than replacing
+	gen   uint64                 // generation of this version of the tree, see PersistentBTree
+	pool  *nodePool              // allocates and recycles nodes, nil for plain allocation
+	mods  uint64                 // no. of modifications, lets an Iterator detect them
+	bloom *bloomFilter           // filters out lookups of absent items, nil if disabled
+	hash  func(item Item) uint64 // hashes items for bloom
+	debug bool                   // check the nodes touched by every update, see SetDebug
+
+	decodeJSON func(data []byte) (Item, error) // see SetJSONDecoder
 }
 
+// NewBTree creates an empty BTree with minimum degree t.
 // t is the minimum degree a node is allowed to have.
 // Every node must have t <= children <= 2t
 // Exceptions: the root node may have less than t children.
 // Every node must have t-1 <= keys  <= 2t - 1.
 // Exceptions: the root node may have less than t-1 keys.
-// t must be >= 2.
-func newBTree(t int) *btree {
-	if t < 2 {
-		panic("invalid minimum degree for btree, t must be >= 2")
+// t must be >= 2, NewBTree panics otherwise. Use NewBTreeChecked when t comes
+// from user configuration.
+func NewBTree(t int) *BTree {
+	b, err := NewBTreeChecked(t)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewBTreeChecked is like NewBTree but returns an error rather than panicking
+// if t is not a valid minimum degree.
+func NewBTreeChecked(t int) (*BTree, error) {
+	return newBTreeFunc(t, compareItems)
+}
+
+// NewBTreeFunc creates an empty BTree with minimum degree t that orders its
+// items with cmp rather than their Compare method, for instance to sort the
+// same items in reverse or by a different field. cmp must return -1, 0 or 1
+// as a is less than, equal to or greater than b. NewBTreeFunc panics if t is
+// not a valid minimum degree.
+func NewBTreeFunc(t int, cmp func(a, b Item) int) *BTree {
+	b, err := newBTreeFunc(t, withInfinities(cmp))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewReverseBTree creates an empty BTree with minimum degree t that keeps its
+// items in descending order: Min returns the largest item, iteration and
+// ToSlice go from largest to smallest, and range bounds are given largest
+// first. It is NewBTreeFunc with the items' Compare flipped.
+func NewReverseBTree(t int) *BTree {
+	return NewBTreeFunc(t, compareItemsReverse)
+}
+
+// NewBTreeWithTiebreak creates an empty BTree with minimum degree t for
+// composite keys: items are ordered by their Compare and, among those that
+// Compare equal, by tiebreak, which has the same contract as the cmp of
+// NewBTreeFunc. Items equal on Compare but not on tiebreak are distinct keys
+// that sit side by side, so inserting one never replaces another; only an
+// item equal on both replaces the stored one. Search, Delete and every other
+// lookup likewise match on the full comparison, finding an item takes both
+// parts of its key, and Validate only reports items equal on both as
+// duplicates. The items sharing a primary key are adjacent in the tree's
+// order.
+func NewBTreeWithTiebreak(t int, tiebreak func(a, b Item) int) *BTree {
+	return NewBTreeFunc(t, func(a, b Item) int {
+		if c := a.Compare(b); c != equal {
+			return c
+		}
+		return tiebreak(a, b)
+	})
+}
+
+func newBTreeFunc(t int, cmp func(a, b Item) int) (*BTree, error) {
+	if err := checkDegree(t); err != nil {
+		return nil, err
 	}
 	x := newNode(t, true)
-	return &btree{
+	return &BTree{
 		t:    t,
 		root: x,
+		cmp:  cmp,
+	}, nil
+}
+
+// compareItems is the comparator of trees created without NewBTreeFunc.
+func compareItems(a, b Item) int {
+	if c, ok := compareInfinities(a, b); ok {
+		return c
+	}
+	return a.Compare(b)
+}
+
+func compareItemsReverse(a, b Item) int {
+	return b.Compare(a)
+}
+
+// NewMultiBTree creates an empty BTree with minimum degree t that behaves as a
+// multiset: Insert stores an item alongside any equal items already present
+// instead of replacing them, and Delete removes a single occurrence. Search
+// returns any one of the equal items, use Count for the multiplicity.
+func NewMultiBTree(t int) *BTree {
+	b := NewBTree(t)
+	b.multi = true
+	return b
+}
+
+// NewBTreeWithPool creates an empty BTree with minimum degree t that recycles
+// the nodes freed by deletes through a sync.Pool, cutting down on allocation
+// for workloads that mix many inserts and deletes.
+func NewBTreeWithPool(t int) *BTree {
+	b := NewBTree(t)
+	b.pool = newNodePool(t)
+	return b
+}
+
+// NewCompactBTree creates an empty BTree with minimum degree t whose leaves
+// allocate room for their items on demand, up to 2t-1, rather than all at
+// once. This saves memory in trees with many sparsely filled leaves at the
+// cost of the occasional reallocation as a leaf fills up.
+func NewCompactBTree(t int) *BTree {
+	b := NewBTree(t)
+	b.pool = &nodePool{compact: true}
+	b.root = b.pool.newNode(t, true)
+	return b
+}
+
+// NewBTreeWithCapacity is like NewCompactBTree but a new leaf starts out with
+// room for nodeCapacity items, clamped to [0, 2t-1], growing from there as
+// needed. A capacity of t-1 sizes the leaf split off a full one exactly to the
+// items it receives. This only saves memory for leaves that stay sparse: under
+// random inserts at t=128 leaves fill up and are reallocated on the way, so
+// the tree ends up no smaller while allocating twice the bytes per split of
+// NewBTree, see BenchmarkSplitCapacity.
+func NewBTreeWithCapacity(t int, nodeCapacity int) *BTree {
+	b := NewBTree(t)
+	if nodeCapacity < 0 {
+		nodeCapacity = 0
+	} else if nodeCapacity > 2*t-1 {
+		nodeCapacity = 2*t - 1
+	}
+	b.pool = &nodePool{compact: true, leafCap: nodeCapacity}
+	b.root = b.pool.newNode(t, true)
+	return b
+}
+
+// checkDegree reports whether t is a valid minimum degree.
+func checkDegree(t int) error {
+	if t < 2 {
+		return fmt.Errorf("invalid minimum degree for btree %d, t must be >= 2", t)
+	}
+	return nil
+}
+
+// Search returns the stored item equal to item, or nil if there is none.
+func (b *BTree) Search(item Item) Item {
+	if b.bloomMiss(item) {
+		return nil
+	}
+	return b.root.search(b.cmp, item)
+}
+
+// searchPath returns the nodes visited by a search for item, from the root
+// down to the node holding it or, if it is absent, to a leaf. The nodes are
+// the tree's own and must not be modified.
+func (b *BTree) searchPath(item Item) []*node {
+	path := make([]*node, 0, b.Height())
+	n := b.root
+	for {
+		path = append(path, n)
+		i, found := n.find(b.cmp, item)
+		if found || n.isLeaf {
+			return path
+		}
+		n = n.children[i]
+	}
+}
+
+// locate returns the node holding the item equal to item and its index in
+// n.items, with ok false if there is none. The node is the tree's own and may
+// be shared with clones, so it must not be modified without first going
+// through mutableFor.
+func (b *BTree) locate(item Item) (n *node, index int, ok bool) {
+	n = b.root
+	for {
+		i, found := n.find(b.cmp, item)
+		if found {
+			return n, i, true
+		}
+		if n.isLeaf {
+			return nil, 0, false
+		}
+		n = n.children[i]
+	}
+}
+
+// Contains reports whether the tree holds an item equal to item.
+func (b *BTree) Contains(item Item) bool {
+	return b.Search(item) != nil
+}
+
+// Floor returns the largest item in the tree less than or equal to item, or
+// nil if there is none. Unlike Search, item need not be present in the tree.
+func (b *BTree) Floor(item Item) Item {
+	return b.root.floor(b.cmp, item)
+}
+
+// Ceiling returns the smallest item in the tree greater than or equal to item,
+// or nil if there is none. Unlike Search, item need not be present in the tree.
+func (b *BTree) Ceiling(item Item) Item {
+	return b.root.ceiling(b.cmp, item)
+}
+
+// Predecessor returns the largest item in the tree strictly less than item, or
+// nil if there is none. item need not be present in the tree.
+func (b *BTree) Predecessor(item Item) Item {
+	return b.root.predecessor(b.cmp, item)
+}
+
+// Successor returns the smallest item in the tree strictly greater than item,
+// or nil if there is none. item need not be present in the tree.
+func (b *BTree) Successor(item Item) Item {
+	return b.root.successor(b.cmp, item)
+}
+
+// Nearest returns the k items in the tree closest to probe according to dist,
+// which must be non-negative and grow with the distance between a and b in
+// the tree's order, e.g. the absolute difference of two numbers. The items are
+// ordered by distance, ties going to the smaller item. Fewer than k items are
+// returned if the tree does not hold that many. The search expands outward
+// from the floor and ceiling of probe one predecessor or successor at a time,
+// taking O(k log n). In a multiset equal items are only returned once.
+func (b *BTree) Nearest(probe Item, k int, dist func(a, b Item) int) []Item {
+	var items []Item
+	lo, hi := b.Floor(probe), b.Ceiling(probe)
+	if lo != nil && hi != nil && b.cmp(lo, hi) == equal {
+		// probe is present, only take it once
+		hi = b.Successor(hi)
+	}
+	for len(items) < k && (lo != nil || hi != nil) {
+		if hi == nil || (lo != nil && dist(probe, lo) <= dist(probe, hi)) {
+			items = append(items, lo)
+			lo = b.Predecessor(lo)
+		} else {
+			items = append(items, hi)
+			hi = b.Successor(hi)
+		}
+	}
+	return items
+}
+
+// Min returns the smallest item in the tree, or nil if the tree is empty.
+func (b *BTree) Min() Item {
+	return b.root.min()
+}
+
+// Max returns the largest item in the tree, or nil if the tree is empty.
+func (b *BTree) Max() Item {
+	return b.root.max()
+}
+
+// Insert adds item to the tree. If an equal item is already present it is
+// replaced and returned, otherwise nil is returned. In a multiset tree item is
+// always added and nil returned.
+func (b *BTree) Insert(item Item) (prev Item) {
+	prev, _ = b.InsertDepth(item)
+	return prev
+}
+
+// InsertDepth is like Insert but also reports the no. of nodes visited on the
+// way to where item landed, counting the new root if the insert made the
+// tree grow. Inserts that replace an item in an internal node stop short of
+// the leaves.
+func (b *BTree) InsertDepth(item Item) (prev Item, depth int) {
+	return b.insert(item, nil)
+}
+
+// InsertWithSplitCount is like Insert but also reports the no. of node splits
+// the insert made, a root split included, to observe how rarely they happen.
+func (b *BTree) InsertWithSplitCount(item Item) (prev Item, splits int) {
+	prev, _ = b.insert(item, &splits)
+	return prev, splits
+}
+
+// insert is InsertDepth, also incrementing splits, if not nil, for every node
+// split on the way down.
+func (b *BTree) insert(item Item, splits *int) (prev Item, depth int) {
+	checkInsertable(item)
+	if b.debug {
+		b.debugProbe("Insert", item)
+	}
+	b.bloomAdd(item)
+	if b.prepareInsert() && splits != nil {
+		*splits++
+	}
+	if b.multi {
+		depth = b.root.insertMulti(b.t, b.pool, b.cmp, item, splits)
+		b.len++
+		if b.debug {
+			b.debugCheck("Insert", item)
+		}
+		return nil, depth
+	}
+	prev, depth = b.root.insert(b.t, b.pool, b.cmp, item, true, splits)
+	if prev == nil {
+		b.len++
+	}
+	if b.debug {
+		b.debugCheck("Insert", item)
+	}
+	return prev, depth
+}
+
+// GetOrInsert returns the stored item equal to item with loaded set if there
+// is one. Otherwise it inserts item and returns it with loaded unset. Unlike a
+// Search followed by an Insert it descends the tree only once.
+func (b *BTree) GetOrInsert(item Item) (actual Item, loaded bool) {
+	checkInsertable(item)
+	if b.debug {
+		b.debugProbe("GetOrInsert", item)
+	}
+	b.bloomAdd(item)
+	b.prepareInsert()
+	prev, _ := b.root.insert(b.t, b.pool, b.cmp, item, false, nil)
+	if b.debug {
+		b.debugCheck("GetOrInsert", item)
+	}
+	if prev != nil {
+		return prev, true
 	}
+	b.len++
+	return item, false
 }
 
-func (b *btree) search(item item) item {
-	return b.root.search(item)
+// ConflictPolicy tells InsertPolicy what to do when an item equal to the one
+// being inserted is already present.
+type ConflictPolicy int
+
+const (
+	ConflictReplace ConflictPolicy = iota // replace the present item, like Insert
+	ConflictKeep                          // keep the present item, like GetOrInsert
+	ConflictFail                          // keep the present item and report a conflict
+)
+
+// InsertAction reports what InsertPolicy did.
+type InsertAction int
+
+const (
+	Inserted   InsertAction = iota // item was added, there was no equal item
+	Replaced                       // item replaced the equal item
+	Kept                           // the equal item was kept under ConflictKeep
+	Conflicted                     // the equal item was kept under ConflictFail
+)
+
+// InsertPolicy adds item to the tree unless an equal item is already present,
+// in which case policy decides whether item replaces it. prev is the equal
+// item if there was one, whether or not it was replaced, and action reports
+// which of the outcomes happened. len only grows when action is Inserted. In a
+// multiset tree item is always inserted.
+func (b *BTree) InsertPolicy(item Item, policy ConflictPolicy) (prev Item, action InsertAction) {
+	if policy == ConflictReplace || b.multi {
+		if prev = b.Insert(item); prev != nil {
+			return prev, Replaced
+		}
+		return nil, Inserted
+	}
+	actual, loaded := b.GetOrInsert(item)
+	switch {
+	case !loaded:
+		return nil, Inserted
+	case policy == ConflictKeep:
+		return actual, Kept
+	default:
+		return actual, Conflicted
+	}
 }
 
-func (b *btree) insert(item item) (prev item) {
+// Replace overwrites the stored item equal to item and returns the previous
+// one with ok set. If there is no equal item the tree is left unchanged and ok
+// is unset; unlike Insert, Replace never adds a new key.
+func (b *BTree) Replace(item Item) (prev Item, ok bool) {
+	// look before copying, a miss must not copy the path of a shared tree
+	if _, _, ok := b.locate(item); !ok {
+		return nil, false
+	}
+	b.mods++
+	b.root = b.root.mutableFor(b.gen)
+	n := b.root
+	for {
+		i, found := n.find(b.cmp, item)
+		if found {
+			prev, n.items[i] = n.items[i], item
+			return prev, true
+		}
+		n = n.mutableChild(i)
+	}
+}
+
+// UpsertValue stores item in the tree and returns the equal item it replaced,
+// if any. Unlike Insert, which may split full nodes on the way down even when
+// the key turns out to be present, an equal item is overwritten in place with
+// no structural change and len is left as is; only a new key goes through
+// the regular insert, growing len by one. This makes it the cheaper way to
+// update the value carried by an existing key.
+func (b *BTree) UpsertValue(item Item) (prev Item) {
+	if prev, ok := b.Replace(item); ok {
+		return prev
+	}
+	return b.Insert(item)
+}
+
+// prepareInsert makes the root safe to insert into: owned by this tree's
+// generation and, if full, split so the tree grows a level, in which case it
+// returns true.
+func (b *BTree) prepareInsert() (split bool) {
+	b.mods++
+	b.root = b.root.mutableFor(b.gen)
 	if b.root.n == (2*b.t - 1) {
 		oldRoot := b.root
-		b.root = newNode(b.t, false)
+		b.root = b.pool.newNode(b.t, false)
+		b.root.gen = b.gen
 		b.root.children[0] = oldRoot
-		b.root.splitChild(b.t, 0)
+		b.root.size = oldRoot.size
+		b.root.splitChild(b.t, b.pool, 0)
+		return true
 	}
-	prev = b.root.insert(b.t, item)
-	if prev == nil {
-		b.len++
+	return false
+}
+
+// Delete removes the item equal to item from the tree and returns it, or
+// returns nil if there is none. In a multiset tree only one of the equal
+// items is removed.
+func (b *BTree) Delete(item Item) (removed Item) {
+	return b.remove(item, removeItem)
+}
+
+// Pop removes the item equal to item from the tree and returns it with ok set,
+// the counterpart of GetOrInsert. If there is no such item ok is unset and the
+// tree keeps the same items, though as with Delete, which Pop shares its single
+// descent with, nodes along the way may have been rebalanced.
+func (b *BTree) Pop(item Item) (removed Item, ok bool) {
+	if b.bloomMiss(item) {
+		return nil, false
+	}
+	removed = b.remove(item, removeItem)
+	return removed, removed != nil
+}
+
+// DeleteMin removes and returns the smallest item in the tree, or returns nil
+// if the tree is empty.
+func (b *BTree) DeleteMin() Item {
+	return b.remove(nil, removeMin)
+}
+
+// DeleteMax removes and returns the largest item in the tree, or returns nil
+// if the tree is empty.
+func (b *BTree) DeleteMax() Item {
+	return b.remove(nil, removeMax)
+}
+
+// PopMaxN removes up to k of the largest items from the tree and returns them
+// in descending order, each removal costing O(height) like DeleteMax. It
+// returns an empty slice if k <= 0.
+func (b *BTree) PopMaxN(k int) []Item {
+	if k > b.len {
+		k = b.len
+	}
+	if k <= 0 {
+		return []Item{}
+	}
+	items := make([]Item, 0, k)
+	for len(items) < k {
+		items = append(items, b.DeleteMax())
+	}
+	return items
+}
+
+func (b *BTree) remove(item Item, typ toRemove) (removed Item) {
+	if b.len == 0 {
+		return nil
+	}
+	b.mods++
+	b.root = b.root.mutableFor(b.gen)
+	removed = b.root.remove(b.t, b.pool, b.cmp, item, typ)
+	if b.root.n == 0 && !b.root.isLeaf {
+		// root emptied out after a merge, shrink the tree height
+		oldRoot := b.root
+		b.root = oldRoot.children[0]
+		b.pool.free(oldRoot)
+	}
+	if removed != nil {
+		b.len--
+	}
+	if b.debug {
+		switch typ {
+		case removeMin:
+			b.debugCheck("DeleteMin", b.Min())
+		case removeMax:
+			b.debugCheck("DeleteMax", b.Max())
+		default:
+			b.debugCheck("Delete", b.deleteProbes(item)...)
+		}
 	}
 	return
 }
+
+// Clear removes every item from the tree, leaving it empty and ready for reuse.
+// The root node's items slice is kept to reduce allocations when a tree is
+// repeatedly filled and emptied.
+func (b *BTree) Clear() {
+	b.mods++
+	if b.bloom != nil {
+		b.bloom.reset()
+	}
+	if b.root.gen != b.gen {
+		// root is shared with another version of the tree, leave it be
+		b.root = b.pool.newNode(b.t, true)
+		b.root.gen = b.gen
+	} else {
+		r := b.root
+		for i := range r.items {
+			r.items[i] = nil
+		}
+		r.isLeaf, r.n, r.size, r.children = true, 0, 0, nil
+	}
+	b.len = 0
+}
+
+// Len returns the number of items in the tree.
+func (b *BTree) Len() int {
+	return b.len
+}
+
+// IsEmpty reports whether the tree holds no items.
+func (b *BTree) IsEmpty() bool {
+	return b.len == 0
+}
+
+// Clone returns an independent copy of the tree: inserts and deletes on one do
+// not affect the other. The nodes are copied but the items themselves are
+// shared, since Item is an interface any item that refers to mutable state
+// remains visible through both trees.
+func (b *BTree) Clone() *BTree {
+	c := *b
+	c.root = b.root.clone(c.gen)
+	if b.bloom != nil {
+		c.bloom = b.bloom.copy()
+	}
+	return &c
+}
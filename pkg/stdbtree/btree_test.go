@@ -1,8 +1,11 @@
 package stdbtree
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"runtime"
+	"sort"
 	"testing"
 	"time"
 
@@ -10,96 +13,19 @@ import (
 )
 
 // for debugging/testing
-func checkInvariances(b *btree, expectedLen int) error {
-	if b.len != expectedLen {
-		return fmt.Errorf("Expected btree to have len %d, instead has len %d", expectedLen, b.len)
-	}
-	var traverseItems func(n *node, fn func(i item))
-	traverseItems = func(n *node, fn func(i item)) {
-		var i int
-		for i = 0; i < n.n; i++ {
-			// first traverse children if internal
-			if !n.isLeaf {
-				traverseItems(n.children[i], fn)
-			}
-			fn(n.items[i])
-		}
-		// traverse last child
-		if !n.isLeaf {
-			traverseItems(n.children[i], fn)
-		}
-	}
-
-	// check that there are no duplicates and all items are in ascending order
-	// this also implictly checks that for every key k, all the items at that subtree
-	// are less than key k
-	var items []item
-	traverseItems(b.root, func(i item) {
-		items = append(items, i)
-	})
-	for i := 1; i < len(items); i++ {
-		switch items[i].compare(items[i-1]) {
-		case equal:
-			return fmt.Errorf("btree contains duplicate items: %v, %v", items[i-1], items[i])
-		case lessThan:
-			return fmt.Errorf("btree items not in sorted order (ascending)\n: %v comes before %v", items[i-1], items[i])
-		}
-	}
-
-	// preOrder-ish traversal, ie traverse node then children
-	var traverseNode func(n *node, fn func(n *node))
-	traverseNode = func(n *node, fn func(n *node)) {
-		fn(n)
-		if !n.isLeaf {
-			for i := 0; i < n.n+1; i++ {
-				traverseNode(n.children[i], fn)
-			}
-		}
-	}
-
-	// check that all nodes have correct n
-	if b.root.n > 2*b.t-1 {
-		return fmt.Errorf("Root node has invalid n: %d", b.root.n)
-	}
-	var err error
-	if !b.root.isLeaf {
-		for i := 0; i < b.root.n+1; i++ {
-			traverseNode(b.root.children[i], func(n *node) {
-				if n.n < b.t-1 || n.n > 2*b.t-1 {
-					err = fmt.Errorf("One of the nodes has invalid n: %d", n.n)
-				}
-			})
-		}
-	}
-	if err != nil {
-		return err
-	}
-
-	// check that all leaves are at same height
-	var leafHeights []int
-	var traverseHeight func(n *node, level int)
-	traverseHeight = func(n *node, level int) {
-		if n.isLeaf {
-			leafHeights = append(leafHeights, level)
-		} else {
-			for i := 0; i <= n.n; i++ {
-				traverseHeight(n.children[i], level+1)
-			}
-		}
+func checkInvariances(b *BTree, expectedLen int) error {
+	if b.Len() != expectedLen {
+		return fmt.Errorf("Expected btree to have len %d, instead has len %d", expectedLen, b.Len())
 	}
-	traverseHeight(b.root, 1)
-	height := leafHeights[0]
-	for _, h := range leafHeights {
-		if h != height {
-			return fmt.Errorf("one of the leaf nodes does not have the same height as the rest: %d vs %d", h, height)
-		}
+	if b.IsEmpty() != (expectedLen == 0) {
+		return fmt.Errorf("Expected btree with len %d to have IsEmpty %v", expectedLen, !b.IsEmpty())
 	}
-	return nil
+	return b.Validate()
 }
 
 type numItem int
 
-func (n numItem) compare(other item) int {
+func (n numItem) Compare(other Item) int {
 	otherNum, ok := other.(numItem)
 	if !ok {
 		panic("invalid item type for comparison")
@@ -114,9 +40,14 @@ func (n numItem) compare(other item) int {
 
 func TestBtreeBasic(t *testing.T) {
 	// check that t must b >= 2
-	require.Panics(t, func() {
-		newBTree(1)
-	})
+	for _, degree := range []int{-1, 0, 1} {
+		b, err := NewBTreeChecked(degree)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Panics(t, func() {
+			NewBTree(degree)
+		})
+	}
 
 	// test parameters
 	seedVal := time.Now().UnixNano()
@@ -133,36 +64,1406 @@ func TestBtreeBasic(t *testing.T) {
 	rand.Shuffle(len(nums), func(i, j int) { nums[i], nums[j] = nums[j], nums[i] })
 
 	// newBtree
-	b := newBTree(T)
+	b := NewBTree(T)
 	require.NotNil(t, b)
 	require.NoError(t, checkInvariances(b, 0), testInfo)
 
 	for _, num := range nums {
-		prev := b.insert(num)
+		prev := b.Insert(num)
 		require.Nil(t, prev, testInfo)
 	}
 	require.NoError(t, checkInvariances(b, N), testInfo)
+	require.Equal(t, N, b.Len(), testInfo)
 
 	// reinsert N items
 	for _, num := range nums {
-		prev := b.insert(num)
+		prev := b.Insert(num)
 		require.NotNil(t, prev, testInfo)
-		require.Equal(t, equal, prev.compare(num))
+		require.Equal(t, equal, prev.Compare(num))
 	}
 	require.NoError(t, checkInvariances(b, N), testInfo)
 
 	// search for N items that we know are present
 	for _, num := range nums {
-		found := b.search(num)
+		found := b.Search(num)
 		require.NotNil(t, found, testInfo)
-		require.Equal(t, equal, num.compare(found))
+		require.Equal(t, equal, num.Compare(found))
 
 	}
 	require.NoError(t, checkInvariances(b, N), testInfo)
 
 	// search for 50 items that we know are NOT present
 	for i := N; i < N+50; i++ {
-		found := b.search(numItem(i))
+		found := b.Search(numItem(i))
 		require.Nil(t, found, testInfo)
+		require.False(t, b.Contains(numItem(i)), testInfo)
+	}
+	for _, num := range nums {
+		require.True(t, b.Contains(num), testInfo)
+	}
+}
+
+func TestBtreeDelete(t *testing.T) {
+	// test parameters
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+
+	// deleting from an empty tree is a no-op
+	require.Nil(t, b.Delete(numItem(0)), testInfo)
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+
+	var nums []numItem
+	for i := 0; i < N; i++ {
+		nums = append(nums, numItem(i))
+	}
+	rand.Shuffle(len(nums), func(i, j int) { nums[i], nums[j] = nums[j], nums[i] })
+	for _, num := range nums {
+		b.Insert(num)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+
+	// delete half the items, checking invariants after every delete
+	rand.Shuffle(len(nums), func(i, j int) { nums[i], nums[j] = nums[j], nums[i] })
+	deleted, kept := nums[:N/2], nums[N/2:]
+	for i, num := range deleted {
+		removed := b.Delete(num)
+		require.NotNil(t, removed, testInfo)
+		require.Equal(t, equal, removed.Compare(num), testInfo)
+		require.NoError(t, checkInvariances(b, N-i-1), testInfo)
+	}
+
+	// deleting absent items returns nil and leaves len unchanged
+	for _, num := range deleted {
+		require.Nil(t, b.Delete(num), testInfo)
+		require.Nil(t, b.Search(num), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, len(kept)), testInfo)
+	for _, num := range kept {
+		require.NotNil(t, b.Search(num), testInfo)
+	}
+
+	// arbitrary interleaving of inserts and deletes
+	present := make(map[numItem]bool)
+	for _, num := range kept {
+		present[num] = true
+	}
+	for i := 0; i < 5*N; i++ {
+		num := numItem(rand.Intn(N))
+		if rand.Intn(2) == 0 {
+			prev := b.Insert(num)
+			require.Equal(t, present[num], prev != nil, testInfo)
+			present[num] = true
+		} else {
+			removed := b.Delete(num)
+			require.Equal(t, present[num], removed != nil, testInfo)
+			delete(present, num)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+
+	// delete everything, tree should shrink back down to a single empty leaf
+	for num := range present {
+		require.False(t, b.IsEmpty(), testInfo)
+		require.NotNil(t, b.Delete(num), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+	require.True(t, b.IsEmpty(), testInfo)
+	require.True(t, b.root.isLeaf, testInfo)
+	require.Equal(t, 0, b.root.n, testInfo)
+}
+
+func TestBtreeMinMax(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Nil(t, b.Min(), testInfo)
+	require.Nil(t, b.Max(), testInfo)
+
+	lo, hi := numItem(N), numItem(-1)
+	for _, i := range rand.Perm(N) {
+		num := numItem(i)
+		b.Insert(num)
+		if num < lo {
+			lo = num
+		}
+		if num > hi {
+			hi = num
+		}
+		require.Equal(t, lo, b.Min(), testInfo)
+		require.Equal(t, hi, b.Max(), testInfo)
+	}
+
+	// min and max track deletes of the boundary items
+	for i := 0; i < N/2; i++ {
+		b.Delete(numItem(i))
+		b.Delete(numItem(N - i - 1))
+		if i < N/2-1 {
+			require.Equal(t, numItem(i+1), b.Min(), testInfo)
+			require.Equal(t, numItem(N-i-2), b.Max(), testInfo)
+		}
+	}
+	require.Nil(t, b.Min(), testInfo)
+	require.Nil(t, b.Max(), testInfo)
+}
+
+func TestBtreeFloorCeiling(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Nil(t, b.Floor(numItem(0)), testInfo)
+	require.Nil(t, b.Ceiling(numItem(0)), testInfo)
+
+	// store multiples of 3 in [0, N)
+	for _, i := range rand.Perm(N / 3) {
+		b.Insert(numItem(3 * i))
+	}
+	for i := -5; i < N+5; i++ {
+		probe := numItem(i)
+		var floor, ceiling Item
+		if i >= 0 {
+			f := i - i%3
+			if f >= N {
+				f = N - 3
+			}
+			floor = numItem(f)
+		}
+		if i < N-2 {
+			c := i
+			if i < 0 {
+				c = 0
+			}
+			for c%3 != 0 {
+				c++
+			}
+			ceiling = numItem(c)
+		}
+		require.Equal(t, floor, b.Floor(probe), testInfo, probe)
+		require.Equal(t, ceiling, b.Ceiling(probe), testInfo, probe)
+	}
+}
+
+func TestBtreePredecessorSuccessor(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Nil(t, b.Predecessor(numItem(0)), testInfo)
+	require.Nil(t, b.Successor(numItem(0)), testInfo)
+
+	// store even numbers in [0, N) so probes are both present and absent
+	for _, i := range rand.Perm(N / 2) {
+		b.Insert(numItem(2 * i))
+	}
+	for i := -3; i < N+3; i++ {
+		probe := numItem(i)
+		var pred, succ Item
+		for j := 0; j < N; j += 2 {
+			if j < i {
+				pred = numItem(j)
+			}
+			if j > i && succ == nil {
+				succ = numItem(j)
+			}
+		}
+		require.Equal(t, pred, b.Predecessor(probe), testInfo, probe)
+		require.Equal(t, succ, b.Successor(probe), testInfo, probe)
+	}
+
+	// the successor of every internal separator is the min of its right
+	// subtree, and its predecessor is the max of its left subtree
+	require.False(t, b.root.isLeaf, testInfo)
+	for i := 0; i < b.root.n; i++ {
+		sep := b.root.items[i]
+		require.Equal(t, b.root.children[i+1].min(), b.Successor(sep), testInfo)
+		require.Equal(t, b.root.children[i].max(), b.Predecessor(sep), testInfo)
+	}
+}
+
+func TestMultiBtree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 50
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewMultiBTree(T)
+	counts := make(map[numItem]int)
+	var total int
+	for i := 0; i < 20*N; i++ {
+		num := numItem(rand.Intn(N))
+		if rand.Intn(3) > 0 {
+			require.Nil(t, b.Insert(num), testInfo)
+			counts[num]++
+			total++
+		} else {
+			removed := b.Delete(num)
+			require.Equal(t, counts[num] > 0, removed != nil, testInfo)
+			if removed != nil {
+				counts[num]--
+				total--
+			}
+		}
+		require.NoError(t, checkInvariances(b, total), testInfo)
+	}
+
+	for i := -1; i <= N; i++ {
+		num := numItem(i)
+		require.Equal(t, counts[num], b.Count(num), testInfo, num)
+		require.Equal(t, counts[num] > 0, b.Search(num) != nil, testInfo, num)
+	}
+
+	// neighbours skip over every equal item
+	for i := 0; i < N; i++ {
+		num := numItem(i)
+		var pred, succ Item
+		for j := 0; j < N; j++ {
+			if counts[numItem(j)] == 0 {
+				continue
+			}
+			if j < i {
+				pred = numItem(j)
+			}
+			if j > i && succ == nil {
+				succ = numItem(j)
+			}
+		}
+		require.Equal(t, pred, b.Predecessor(num), testInfo, num)
+		require.Equal(t, succ, b.Successor(num), testInfo, num)
+	}
+
+	// each delete removes a single occurrence
+	for num, count := range counts {
+		for ; count > 0; count-- {
+			require.Equal(t, count, b.Count(num), testInfo)
+			require.NotNil(t, b.Delete(num), testInfo)
+			total--
+		}
+		require.Nil(t, b.Delete(num), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+}
+
+func TestBtreeClone(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	c := b.Clone()
+	require.NoError(t, checkInvariances(c, N), testInfo)
+	require.Equal(t, b.ToSlice(), c.ToSlice(), testInfo)
+
+	// mutating the clone leaves the original unchanged
+	for i := N; i < 2*N; i++ {
+		c.Insert(numItem(i))
+	}
+	for i := 0; i < N; i += 2 {
+		c.Delete(numItem(i))
+	}
+	require.NoError(t, checkInvariances(c, N+N/2), testInfo)
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	for i := 0; i < N; i++ {
+		require.Equal(t, numItem(i), b.Search(numItem(i)), testInfo)
+	}
+	require.Nil(t, b.Search(numItem(N)), testInfo)
+
+	// and the other way round
+	b.Delete(numItem(1))
+	require.NotNil(t, c.Search(numItem(1)), testInfo)
+}
+
+func TestBtreeGetOrInsert(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	present := make(map[int]bool)
+	for i := 0; i < 3*N; i++ {
+		num := rand.Intn(N)
+		actual, loaded := b.GetOrInsert(numItem(num))
+		require.Equal(t, present[num], loaded, testInfo)
+		require.Equal(t, numItem(num), actual, testInfo)
+		present[num] = true
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+
+	// an existing item is returned as is and not replaced
+	b = NewBTree(T)
+	stored := &idItem{key: 1, id: 1}
+	b.Insert(stored)
+	actual, loaded := b.GetOrInsert(&idItem{key: 1, id: 2})
+	require.True(t, loaded, testInfo)
+	require.Same(t, stored, actual, testInfo)
+	require.Same(t, stored, b.Search(&idItem{key: 1}), testInfo)
+	require.Equal(t, 1, b.Len(), testInfo)
+}
+
+// idItem is compared on key only, so items with different ids are equal
+type idItem struct {
+	key int
+	id  int
+}
+
+func (n *idItem) Compare(other Item) int {
+	return numItem(n.key).Compare(numItem(other.(*idItem).key))
+}
+
+func TestBtreeDeleteMinMax(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Nil(t, b.DeleteMin(), testInfo)
+	require.Nil(t, b.DeleteMax(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	// pop alternately from both ends
+	for i := 0; i < N/2; i++ {
+		require.Equal(t, numItem(i), b.DeleteMin(), testInfo)
+		require.NoError(t, checkInvariances(b, N-2*i-1), testInfo)
+		require.Equal(t, numItem(N-i-1), b.DeleteMax(), testInfo)
+		require.NoError(t, checkInvariances(b, N-2*i-2), testInfo)
+	}
+	require.Nil(t, b.DeleteMin(), testInfo)
+	require.Nil(t, b.DeleteMax(), testInfo)
+	require.True(t, b.root.isLeaf, testInfo)
+}
+
+func TestBtreeClear(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for round := 0; round < 3; round++ {
+		for _, i := range rand.Perm(N) {
+			b.Insert(numItem(i))
+		}
+		require.NoError(t, checkInvariances(b, N), testInfo)
+		b.Clear()
+		require.NoError(t, checkInvariances(b, 0), testInfo)
+		for i := 0; i < N; i++ {
+			require.Nil(t, b.Search(numItem(i)), testInfo)
+		}
+	}
+
+	// clearing a clone of a persistent version leaves the version intact
+	p := NewPersistentBTree(T).Insert(numItem(1))
+	c := p.Clone()
+	c.Clear()
+	require.Equal(t, 0, c.Len(), testInfo)
+	require.NotNil(t, p.Search(numItem(1)), testInfo)
+}
+
+func TestBtreeReplace(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	prev, ok := b.Replace(&idItem{key: 0})
+	require.False(t, ok, testInfo)
+	require.Nil(t, prev, testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(&idItem{key: 2 * i, id: 0})
+	}
+	for i := 0; i < 2*N; i++ {
+		prev, ok := b.Replace(&idItem{key: i, id: 1})
+		require.Equal(t, i%2 == 0, ok, testInfo)
+		if ok {
+			require.Equal(t, &idItem{key: i, id: 0}, prev, testInfo)
+			require.Equal(t, 1, b.Search(&idItem{key: i}).(*idItem).id, testInfo)
+		} else {
+			require.Nil(t, prev, testInfo)
+			require.Nil(t, b.Search(&idItem{key: i}), testInfo)
+		}
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+}
+
+func TestBtreeFunc(t *testing.T) {
+	// test parameters
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.Panics(t, func() {
+		NewBTreeFunc(1, compareItems)
+	})
+
+	// order items in reverse
+	desc := func(a, b Item) int { return b.Compare(a) }
+	b := NewBTreeFunc(T, desc)
+	nums := rand.Perm(N)
+	for _, num := range nums {
+		require.Nil(t, b.Insert(numItem(num)), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	for _, num := range nums {
+		require.Equal(t, numItem(num), b.Search(numItem(num)), testInfo)
+		require.NotNil(t, b.Insert(numItem(num)), testInfo)
+	}
+	require.Nil(t, b.Search(numItem(N)), testInfo)
+	require.Equal(t, numItem(N-1), b.Min(), testInfo)
+	require.Equal(t, numItem(0), b.Max(), testInfo)
+
+	items := b.ToSlice()
+	require.Len(t, items, N, testInfo)
+	for i, item := range items {
+		require.Equal(t, numItem(N-1-i), item, testInfo)
+	}
+
+	for _, num := range nums[:N/2] {
+		require.Equal(t, numItem(num), b.Delete(numItem(num)), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N-N/2), testInfo)
+}
+
+func TestBtreeSearchPastLastItem(t *testing.T) {
+	// a packed tree with t=2 has internal nodes holding several items
+	N := 100
+	items := make([]Item, N)
+	for i := range items {
+		items[i] = numItem(2 * i) // leave gaps for absent keys
+	}
+	b, err := NewBTreeFromSorted(2, items)
+	require.NoError(t, err)
+	require.False(t, b.root.isLeaf)
+
+	var checked int
+	var traverseNode func(n *node)
+	traverseNode = func(n *node) {
+		if n.isLeaf {
+			return
+		}
+		if n.n > 1 {
+			// keys greater than every item in n live in its last child
+			last := n.children[n.n]
+			present := last.items[last.n-1]
+			require.Equal(t, present, n.search(b.cmp, present))
+			require.Equal(t, present, b.Search(present))
+			absent := present.(numItem) + 1
+			require.Nil(t, n.search(b.cmp, absent))
+			require.Nil(t, b.Search(absent))
+			checked++
+		}
+		for i := 0; i <= n.n; i++ {
+			traverseNode(n.children[i])
+		}
+	}
+	traverseNode(b.root)
+	require.NotZero(t, checked)
+	require.Nil(t, b.Search(numItem(2*N)))
+	require.Nil(t, b.Search(numItem(-1)))
+}
+
+// large minimum degrees are where searching within a node dominates
+func benchmarkLargeDegree(b *testing.B, fn func(tree *BTree, item Item)) {
+	nums := rand.Perm(100000)
+	tree := NewBTree(256)
+	for _, num := range nums {
+		tree.Insert(numItem(num))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(tree, numItem(nums[i%len(nums)]))
+	}
+}
+
+func BenchmarkSearchLargeDegree(b *testing.B) {
+	benchmarkLargeDegree(b, func(tree *BTree, item Item) {
+		tree.Search(item)
+	})
+}
+
+func BenchmarkInsertLargeDegree(b *testing.B) {
+	benchmarkLargeDegree(b, func(tree *BTree, item Item) {
+		tree.Insert(item)
+	})
+}
+
+// buildNode assembles a node with minimum degree t from its items and, for an
+// internal node, its children, so that tests can lay out a tree exactly.
+func buildNode(t int, items []int, children ...*node) *node {
+	n := newNode(t, len(children) == 0)
+	for i, item := range items {
+		n.items[i] = numItem(item)
+	}
+	n.n = len(items)
+	copy(n.children, children)
+	n.size = n.computeSize()
+	return n
+}
+
+func TestBtreeDeleteFixups(t *testing.T) {
+	levels := func(levels ...[]int) [][]Item {
+		var res [][]Item
+		for _, level := range levels {
+			var items []Item
+			for _, item := range level {
+				items = append(items, numItem(item))
+			}
+			res = append(res, items)
+		}
+		return res
+	}
+	tests := []struct {
+		name     string
+		root     *node
+		key      int
+		expected [][]Item
+	}{
+		{
+			// 20's leaf has t-1 items and its left sibling can spare one:
+			// 3 moves up and the separator 10 moves down
+			name: "borrow from left sibling",
+			root: buildNode(2, []int{10},
+				buildNode(2, []int{1, 2, 3}),
+				buildNode(2, []int{20})),
+			key:      20,
+			expected: levels([]int{3}, []int{1, 2, 10}),
+		},
+		{
+			// mirror image, 11 moves up and the separator 10 moves down
+			name: "borrow from right sibling",
+			root: buildNode(2, []int{10},
+				buildNode(2, []int{1}),
+				buildNode(2, []int{11, 12, 13})),
+			key:      1,
+			expected: levels([]int{11}, []int{10, 12, 13}),
+		},
+		{
+			// neither sibling can spare an item, the rightmost child merges
+			// with its left sibling, emptying the root which is dropped
+			name: "merge with sibling and shrink root",
+			root: buildNode(2, []int{10},
+				buildNode(2, []int{1}),
+				buildNode(2, []int{20})),
+			key:      20,
+			expected: levels([]int{1, 10}),
+		},
+		{
+			// key is the separator itself and both children are minimal
+			name: "merge around deleted separator and shrink root",
+			root: buildNode(2, []int{10},
+				buildNode(2, []int{1}),
+				buildNode(2, []int{20})),
+			key:      10,
+			expected: levels([]int{1, 20}),
+		},
+		{
+			// the merge leaves the root with an item to spare, so the tree
+			// keeps its height
+			name: "merge below root without shrinking",
+			root: buildNode(2, []int{10, 30},
+				buildNode(2, []int{1}),
+				buildNode(2, []int{20}),
+				buildNode(2, []int{40})),
+			key:      1,
+			expected: levels([]int{30}, []int{10, 20, 40}),
+		},
+	}
+
+	for _, tc := range tests {
+		b := NewBTree(2)
+		b.root = tc.root
+		b.len = tc.root.size
+		size := b.len
+		require.NoError(t, checkInvariances(b, size), tc.name)
+		removed := b.Delete(numItem(tc.key))
+		require.Equal(t, numItem(tc.key), removed, tc.name)
+		require.NoError(t, checkInvariances(b, size-1), tc.name)
+		require.Equal(t, tc.expected, b.LevelOrder(), tc.name)
 	}
 }
+
+func TestCompactBtree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	leafCap := rand.Intn(2*T-1) + 1
+	for _, b := range []*BTree{NewCompactBTree(T), NewBTreeWithCapacity(T, leafCap)} {
+		if b.pool.leafCap == 0 {
+			require.Empty(t, b.root.items, testInfo)
+		} else {
+			require.Len(t, b.root.items, leafCap, testInfo)
+		}
+		present := make(map[numItem]bool)
+		for i := 0; i < 10*N; i++ {
+			num := numItem(rand.Intn(N))
+			if rand.Intn(3) > 0 {
+				prev := b.Insert(num)
+				require.Equal(t, present[num], prev != nil, testInfo)
+				present[num] = true
+			} else {
+				removed := b.Delete(num)
+				require.Equal(t, present[num], removed != nil, testInfo)
+				delete(present, num)
+			}
+			require.NoError(t, checkInvariances(b, len(present)), testInfo)
+		}
+
+		// leaves never outgrow a regular node
+		var traverseNode func(n *node)
+		traverseNode = func(n *node) {
+			require.LessOrEqual(t, len(n.items), 2*T-1, testInfo)
+			if !n.isLeaf {
+				require.Len(t, n.items, 2*T-1, testInfo)
+				for i := 0; i <= n.n; i++ {
+					traverseNode(n.children[i])
+				}
+			}
+		}
+		traverseNode(b.root)
+		for num := range present {
+			require.NotNil(t, b.Search(num), testInfo)
+		}
+
+		// rebuilding keeps the leaf mode, each leaf only getting the room an
+		// insert would have reserved for its items
+		checkLeaves := func(c *BTree, op string) {
+			require.True(t, c.pool.compact, testInfo, op)
+			require.NoError(t, checkInvariances(c, len(present)), testInfo, op)
+			var visit func(n *node)
+			visit = func(n *node) {
+				if !n.isLeaf {
+					for i := 0; i <= n.n; i++ {
+						visit(n.children[i])
+					}
+					return
+				}
+				want := c.pool.leafCap
+				if n.n > want {
+					want = 2 * c.pool.leafCap
+					if want < n.n {
+						want = n.n
+					}
+					if want > 2*c.t-1 {
+						want = 2*c.t - 1
+					}
+				}
+				require.Len(t, n.items, want, testInfo, op)
+			}
+			visit(c.root)
+		}
+		pool := b.pool
+		b.Compact()
+		require.Same(t, pool, b.pool, testInfo)
+		checkLeaves(b, "compact")
+		b.Rebuild()
+		require.Same(t, pool, b.pool, testInfo)
+		checkLeaves(b, "rebuild")
+		checkLeaves(b.WithDegree(T+1), "with degree")
+	}
+}
+
+// reports the heap taken up by a tree of 1M items with t=2, the smallest
+// nodes and so the ones where the leaves are most sparsely filled
+func benchmarkTreeMemory(b *testing.B, newTree func(t int) *BTree) {
+	nums := rand.Perm(1000000)
+	var mem runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		before := mem.HeapAlloc
+		tree := newTree(2)
+		for _, num := range nums {
+			tree.Insert(numItem(num))
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		b.ReportMetric(float64(mem.HeapAlloc-before)/float64(len(nums)), "heapB/item")
+		runtime.KeepAlive(tree)
+	}
+}
+
+func BenchmarkTreeMemory(b *testing.B) {
+	benchmarkTreeMemory(b, NewBTree)
+}
+
+// BenchmarkSplit, BenchmarkSplitCompact and BenchmarkSplitCapacity report the
+// bytes allocated per node split at t=128 when leaves are allocated full,
+// empty or with room for the t-1 items a split hands over, and the heap the
+// resulting tree holds on to
+func benchmarkSplit(b *testing.B, newTree func(t int) *BTree) {
+	var items []Item
+	for _, num := range rand.Perm(100000) {
+		items = append(items, numItem(num))
+	}
+	var tree *BTree
+	var splits int
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree = newTree(128)
+		for _, item := range items {
+			tree.Insert(item)
+		}
+		// a split adds a node, as does growing a new root
+		splits += tree.Stats().Nodes - tree.Height()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/float64(splits), "B/split")
+	// and what the last tree holds on to once built
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(tree.Len()), "heapB/item")
+	runtime.KeepAlive(items)
+}
+
+func BenchmarkSplit(b *testing.B) {
+	benchmarkSplit(b, NewBTree)
+}
+
+func BenchmarkSplitCompact(b *testing.B) {
+	benchmarkSplit(b, NewCompactBTree)
+}
+
+func BenchmarkSplitCapacity(b *testing.B) {
+	benchmarkSplit(b, func(t int) *BTree { return NewBTreeWithCapacity(t, t-1) })
+}
+
+func BenchmarkTreeMemoryCompact(b *testing.B) {
+	benchmarkTreeMemory(b, NewCompactBTree)
+}
+
+func TestBtreeInsertDepth(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// fresh keys always land in a leaf
+	for _, b := range []*BTree{NewBTree(T), NewMultiBTree(T)} {
+		for _, i := range rand.Perm(N) {
+			prev, depth := b.InsertDepth(numItem(i))
+			require.Nil(t, prev, testInfo)
+			require.Equal(t, b.Height(), depth, testInfo)
+		}
+		require.NoError(t, checkInvariances(b, N), testInfo)
+	}
+
+	// a replaced key stops at the node holding it
+	b := NewBTree(2)
+	for i := 1; i <= 4; i++ {
+		b.Insert(numItem(i))
+	}
+	prev, depth := b.InsertDepth(numItem(2)) // the root
+	require.Equal(t, numItem(2), prev)
+	require.Equal(t, 1, depth)
+	prev, depth = b.InsertDepth(numItem(4)) // a leaf
+	require.Equal(t, numItem(4), prev)
+	require.Equal(t, 2, depth)
+
+	// a root split counts the new root
+	b = NewBTree(2)
+	for i := 1; i <= 3; i++ {
+		_, depth = b.InsertDepth(numItem(i))
+		require.Equal(t, 1, depth)
+	}
+	_, depth = b.InsertDepth(numItem(4))
+	require.Equal(t, 2, depth)
+}
+
+func TestBtreeInsertWithSplitCount(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// every split adds a node, and a root split another for the new root
+	for _, b := range []*BTree{NewBTree(T), NewMultiBTree(T)} {
+		total := 0
+		for i := 0; i < 3*N; i++ {
+			num := numItem(rand.Intn(N))
+			nodes, height := b.Stats().Nodes, b.Height()
+			wantPrev := b.Search(num)
+			if b.multi {
+				wantPrev = nil
+			}
+			prev, splits := b.InsertWithSplitCount(num)
+			require.Equal(t, wantPrev, prev, testInfo)
+			require.Equal(t, b.Stats().Nodes-nodes, splits+b.Height()-height, testInfo, num)
+			total += splits
+		}
+		require.NoError(t, checkInvariances(b, b.Len()), testInfo)
+		require.Equal(t, b.Stats().Nodes-b.Height(), total, testInfo)
+	}
+
+	// splits cascade down from a full root
+	b := NewBTree(2)
+	b.root = buildNode(2, []int{10, 20, 30},
+		buildNode(2, []int{5}),
+		buildNode(2, []int{15}),
+		buildNode(2, []int{25}),
+		buildNode(2, []int{32, 34, 36}))
+	b.len = b.root.size
+	_, splits := b.InsertWithSplitCount(numItem(38))
+	require.Equal(t, 2, splits)
+	require.NoError(t, checkInvariances(b, 10))
+	// the splits left no full node behind
+	_, splits = b.InsertWithSplitCount(numItem(6))
+	require.Equal(t, 0, splits)
+	_, splits = b.InsertWithSplitCount(numItem(20))
+	require.Equal(t, 0, splits)
+}
+
+// BenchmarkInsertSplits reports the no. of splits per random insert, which
+// shrinks roughly as 1/t
+func BenchmarkInsertSplits(b *testing.B) {
+	items := benchItems()
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := NewBTree(t)
+		total := 0
+		for i := 0; i < b.N; i++ {
+			if i%benchSize == 0 {
+				tree = NewBTree(t)
+			}
+			_, splits := tree.InsertWithSplitCount(items[i%benchSize])
+			total += splits
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "splits/op")
+	})
+}
+
+func absDiff(a, b Item) int {
+	d := int(a.(numItem) - b.(numItem))
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func TestBtreeNearest(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.Nearest(numItem(0), 3, absDiff), testInfo)
+	// a random subset of [0, 3N)
+	for i := 0; i < N; i++ {
+		b.Insert(numItem(rand.Intn(3 * N)))
+	}
+	all := b.ToSlice()
+	for _, k := range []int{0, 1, 5, 20, b.Len(), b.Len() + 1} {
+		for probe := -5; probe < 3*N+5; probe += 7 {
+			// brute force: sort everything by distance, ties to the smaller
+			expected := append([]Item{}, all...)
+			sort.SliceStable(expected, func(i, j int) bool {
+				return absDiff(numItem(probe), expected[i]) < absDiff(numItem(probe), expected[j])
+			})
+			if k < len(expected) {
+				expected = expected[:k]
+			}
+			if len(expected) == 0 {
+				expected = nil
+			}
+			require.Equal(t, expected, b.Nearest(numItem(probe), k, absDiff), "%s probe %d k %d", testInfo, probe, k)
+		}
+	}
+}
+
+func ExampleBTree_Nearest() {
+	b := NewBTree(2)
+	for _, i := range []int{1, 4, 9, 16, 25} {
+		b.Insert(numItem(i))
+	}
+	fmt.Println(b.Nearest(numItem(10), 3, absDiff))
+	// Output: [9 4 16]
+}
+
+func TestBtreeSearchPath(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, []*node{b.root}, b.searchPath(numItem(0)), testInfo)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+
+	// the level each item sits at, root being 1
+	depths := make(map[Item]int)
+	for level, items := range b.LevelOrder() {
+		for _, item := range items {
+			depths[item] = level + 1
+		}
+	}
+	for i := 0; i < N; i++ {
+		present := numItem(2 * i)
+		path := b.searchPath(present)
+		require.Len(t, path, depths[present], testInfo)
+		require.Equal(t, b.root, path[0], testInfo)
+		last := path[len(path)-1]
+		_, found := last.find(b.cmp, present)
+		require.True(t, found, testInfo)
+		for j := 1; j < len(path); j++ {
+			i, _ := path[j-1].find(b.cmp, present)
+			require.Equal(t, path[j-1].children[i], path[j], testInfo)
+		}
+
+		// absent items end at a leaf
+		path = b.searchPath(present + 1)
+		require.Len(t, path, b.Height(), testInfo)
+		require.True(t, path[len(path)-1].isLeaf, testInfo)
+	}
+}
+
+func TestBtreeLocate(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	_, _, ok := b.locate(numItem(0))
+	require.False(t, ok, testInfo)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	for i := 0; i < N; i++ {
+		present := numItem(2 * i)
+		n, index, ok := b.locate(present)
+		require.True(t, ok, testInfo)
+		require.Equal(t, present, n.items[index], testInfo)
+		path := b.searchPath(present)
+		require.Equal(t, path[len(path)-1], n, testInfo)
+
+		n, _, ok = b.locate(present + 1)
+		require.False(t, ok, testInfo)
+		require.Nil(t, n, testInfo)
+	}
+
+	// inserting 4 into the full root [1 2 3] splits it, moving 2 up into a
+	// new root over the leaves [1] and [3 4]
+	b = NewBTree(2)
+	for i := 1; i <= 4; i++ {
+		b.Insert(numItem(i))
+	}
+	n, index, _ := b.locate(numItem(2))
+	require.Equal(t, b.root, n)
+	require.Equal(t, 0, index)
+	require.False(t, n.isLeaf)
+	n, index, _ = b.locate(numItem(4))
+	require.True(t, n.isLeaf)
+	require.Equal(t, 1, index)
+}
+
+func TestBtreeTiebreak(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	byID := func(a, b Item) int {
+		return numItem(a.(*idItem).id).Compare(numItem(b.(*idItem).id))
+	}
+	b := NewBTreeWithTiebreak(T, byID)
+	// ten ids for each of N/10 keys, all distinct
+	for _, i := range rand.Perm(N) {
+		require.Nil(t, b.Insert(&idItem{key: i / 10, id: i % 10}), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	items := b.ToSlice()
+	for i, item := range items {
+		require.Equal(t, &idItem{key: i / 10, id: i % 10}, item, testInfo)
+	}
+
+	// lookups match on both key and id
+	for i := 0; i < N; i++ {
+		probe := &idItem{key: i / 10, id: i % 10}
+		require.Same(t, items[i], b.Search(probe), testInfo)
+		require.Nil(t, b.Search(&idItem{key: i / 10, id: 10}), testInfo)
+		require.Equal(t, 10, b.RangeCount(&idItem{key: i / 10, id: 0}, &idItem{key: i / 10, id: 9}), testInfo)
+	}
+	// only an item equal on both replaces a stored one
+	prev := b.Insert(&idItem{key: 0, id: 0})
+	require.Same(t, items[0], prev, testInfo)
+	require.Equal(t, N, b.Len(), testInfo)
+	require.Same(t, items[1], b.Delete(&idItem{key: 0, id: 1}), testInfo)
+	require.Nil(t, b.Search(&idItem{key: 0, id: 1}), testInfo)
+	require.NotNil(t, b.Search(&idItem{key: 0, id: 2}), testInfo)
+	require.NoError(t, checkInvariances(b, N-1), testInfo)
+
+	// items equal on both are still duplicates to Validate: a copy of the
+	// root's first item's predecessor in its place sits right after it
+	pred := b.Predecessor(b.root.items[0]).(*idItem)
+	b.root.items[0] = &idItem{key: pred.key, id: pred.id}
+	require.True(t, errors.Is(b.Validate(), ErrDuplicate), testInfo)
+}
+
+func TestReverseBtree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewReverseBTree(T)
+	present := make(map[numItem]bool)
+	for i := 0; i < 10*N; i++ {
+		num := numItem(rand.Intn(N))
+		if rand.Intn(3) > 0 {
+			prev := b.Insert(num)
+			require.Equal(t, present[num], prev != nil, testInfo)
+			present[num] = true
+		} else {
+			removed := b.Delete(num)
+			require.Equal(t, present[num], removed != nil, testInfo)
+			delete(present, num)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+
+	var expected []Item
+	for i := N - 1; i >= 0; i-- {
+		if present[numItem(i)] {
+			expected = append(expected, numItem(i))
+		}
+	}
+	require.Equal(t, expected, b.ToSlice(), testInfo)
+	require.Equal(t, expected[0], b.Min(), testInfo)
+	require.Equal(t, expected[len(expected)-1], b.Max(), testInfo)
+	require.Equal(t, expected[:3], b.First(3), testInfo)
+
+	// ranges run from the larger bound down to the smaller
+	var inRange []Item
+	for _, item := range expected {
+		if n := item.(numItem); n <= 200 && n >= 100 {
+			inRange = append(inRange, item)
+		}
+	}
+	require.Equal(t, inRange, b.RangeScan(numItem(200), numItem(100)), testInfo)
+	require.Equal(t, len(inRange), b.RangeCount(numItem(200), numItem(100)), testInfo)
+
+	// neighbours are flipped too
+	b = NewReverseBTree(T)
+	for i := 0; i < N; i += 2 {
+		b.Insert(numItem(i))
+	}
+	require.Equal(t, numItem(12), b.Floor(numItem(11)), testInfo)
+	require.Equal(t, numItem(10), b.Ceiling(numItem(11)), testInfo)
+	require.Equal(t, numItem(12), b.Predecessor(numItem(10)), testInfo)
+	require.Equal(t, numItem(8), b.Successor(numItem(10)), testInfo)
+	require.Equal(t, numItem(N-2), b.DeleteMin(), testInfo)
+	require.Equal(t, numItem(0), b.DeleteMax(), testInfo)
+	require.NoError(t, checkInvariances(b, N/2-2), testInfo)
+}
+
+func TestBtreeUpsertValue(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		require.Nil(t, b.UpsertValue(&idItem{key: i, id: i}), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+
+	// existing keys get their item swapped and nothing else changes
+	shape := b.LevelStats()
+	for _, i := range rand.Perm(N) {
+		prev := b.UpsertValue(&idItem{key: i, id: -i})
+		require.Equal(t, &idItem{key: i, id: i}, prev, testInfo)
+		require.Equal(t, N, b.Len(), testInfo)
+	}
+	require.Equal(t, shape, b.LevelStats(), testInfo)
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	for i, item := range b.ToSlice() {
+		require.Equal(t, &idItem{key: i, id: -i}, item, testInfo)
+	}
+
+	// unlike Insert, which splits a full root before looking for the key
+	b = NewBTree(2)
+	for i := 1; i <= 3; i++ {
+		b.Insert(numItem(i))
+	}
+	b.UpsertValue(numItem(2))
+	require.Equal(t, 1, b.Height())
+	b.Insert(numItem(2))
+	require.Equal(t, 2, b.Height())
+}
+
+func TestBtreePopMaxN(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.PopMaxN(5), testInfo)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	require.Empty(t, b.PopMaxN(0), testInfo)
+	require.Empty(t, b.PopMaxN(-1), testInfo)
+
+	// drain in uneven chunks, the rest of the tree staying intact
+	left := N
+	for left > 0 {
+		k := rand.Intn(30) + 1
+		popped := b.PopMaxN(k)
+		if k > left {
+			k = left
+		}
+		require.Len(t, popped, k, testInfo)
+		for i, item := range popped {
+			require.Equal(t, numItem(left-1-i), item, testInfo)
+		}
+		left -= k
+		require.NoError(t, checkInvariances(b, left), testInfo)
+		for i, item := range b.ToSlice() {
+			require.Equal(t, numItem(i), item, testInfo)
+		}
+	}
+	require.Empty(t, b.PopMaxN(1), testInfo)
+}
+
+func TestBtreeInsertPolicy(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	conflict := map[ConflictPolicy]InsertAction{
+		ConflictReplace: Replaced,
+		ConflictKeep:    Kept,
+		ConflictFail:    Conflicted,
+	}
+	b := NewBTree(T)
+	stored := make(map[int]int) // key to id of the item stored
+	for i := 0; i < 5*N; i++ {
+		key := rand.Intn(N)
+		policy := ConflictPolicy(rand.Intn(3))
+		item := &idItem{key: key, id: i}
+		prev, action := b.InsertPolicy(item, policy)
+		id, present := stored[key]
+		if !present {
+			require.Nil(t, prev, testInfo)
+			require.Equal(t, Inserted, action, testInfo)
+			stored[key] = i
+		} else {
+			require.Equal(t, &idItem{key: key, id: id}, prev, testInfo)
+			require.Equal(t, conflict[policy], action, testInfo)
+			if policy == ConflictReplace {
+				stored[key] = i
+			}
+		}
+		require.Equal(t, stored[key], b.Search(item).(*idItem).id, testInfo)
+		require.NoError(t, checkInvariances(b, len(stored)), testInfo)
+	}
+
+	// a multiset always inserts
+	m := NewMultiBTree(T)
+	for _, policy := range []ConflictPolicy{ConflictReplace, ConflictKeep, ConflictFail} {
+		prev, action := m.InsertPolicy(numItem(1), policy)
+		require.Nil(t, prev, testInfo)
+		require.Equal(t, Inserted, action, testInfo)
+	}
+	require.NoError(t, checkInvariances(m, 3), testInfo)
+}
+
+func TestBtreePop(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	removed, ok := b.Pop(numItem(0))
+	require.False(t, ok, testInfo)
+	require.Nil(t, removed, testInfo)
+
+	present := make(map[int]int) // key to id of the item stored
+	for i := 0; i < 10*N; i++ {
+		key := rand.Intn(N)
+		if rand.Intn(2) == 0 {
+			b.Insert(&idItem{key: key, id: i})
+			present[key] = i
+		} else {
+			before := b.ToSlice()
+			removed, ok := b.Pop(&idItem{key: key})
+			id, want := present[key]
+			require.Equal(t, want, ok, testInfo)
+			if ok {
+				require.Equal(t, &idItem{key: key, id: id}, removed, testInfo)
+			} else {
+				require.Nil(t, removed, testInfo)
+				require.Equal(t, before, b.ToSlice(), testInfo)
+			}
+			delete(present, key)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+}
+
+// the core operations across a range of minimum degrees, on trees of
+// benchSize items
+var benchDegrees = []int{2, 8, 32, 128}
+
+const benchSize = 100000
+
+// benchItems returns the items 0, 2, 4, ... of a tree of benchSize items in
+// random order; odd items are absent from it
+func benchItems() []Item {
+	items := make([]Item, benchSize)
+	for i, num := range rand.Perm(benchSize) {
+		items[i] = numItem(2 * num)
+	}
+	return items
+}
+
+func benchTree(t int, items []Item) *BTree {
+	tree := NewBTree(t)
+	for _, item := range items {
+		tree.Insert(item)
+	}
+	return tree
+}
+
+// benchmarkDegrees runs fn as a sub-benchmark for each of benchDegrees,
+// reporting allocations
+func benchmarkDegrees(b *testing.B, fn func(b *testing.B, t int)) {
+	for _, t := range benchDegrees {
+		b.Run(fmt.Sprintf("t=%d", t), func(b *testing.B) {
+			b.ReportAllocs()
+			fn(b, t)
+		})
+	}
+}
+
+func BenchmarkInsertRandom(b *testing.B) {
+	items := benchItems()
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := NewBTree(t)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if i%benchSize == 0 {
+				b.StopTimer()
+				tree = NewBTree(t)
+				b.StartTimer()
+			}
+			tree.Insert(items[i%benchSize])
+		}
+	})
+}
+
+func BenchmarkInsertSequential(b *testing.B) {
+	items := make([]Item, benchSize)
+	for i := range items {
+		items[i] = numItem(i)
+	}
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := NewBTree(t)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if i%benchSize == 0 {
+				b.StopTimer()
+				tree = NewBTree(t)
+				b.StartTimer()
+			}
+			tree.Insert(items[i%benchSize])
+		}
+	})
+}
+
+func BenchmarkSearchHit(b *testing.B) {
+	items := benchItems()
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := benchTree(t, items)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tree.Search(items[i%benchSize])
+		}
+	})
+}
+
+func BenchmarkSearchMissRandom(b *testing.B) {
+	items := benchItems()
+	misses := make([]Item, benchSize)
+	for i, item := range items {
+		misses[i] = item.(numItem) + 1
+	}
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := benchTree(t, items)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tree.Search(misses[i%benchSize])
+		}
+	})
+}
+
+func BenchmarkDelete(b *testing.B) {
+	items := benchItems()
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		var tree *BTree
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if i%benchSize == 0 {
+				b.StopTimer()
+				tree = benchTree(t, items)
+				b.StartTimer()
+			}
+			tree.Delete(items[i%benchSize])
+		}
+	})
+}
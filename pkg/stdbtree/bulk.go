@@ -0,0 +1,292 @@
+package stdbtree
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NewBTreeFromSorted builds a BTree with minimum degree t holding items, which
+// must be in strictly ascending order. Rather than inserting items one at a
+// time, the tree is assembled bottom-up in O(N): items are spread evenly
+// across as few leaves as possible, and the separators between them become
+// the items of the level above, until a single root remains. The resulting
+// nodes are packed as densely as the invariants allow.
+//
+// An error is returned if t is invalid or items is not sorted or contains
+// duplicates.
+func NewBTreeFromSorted(t int, items []Item) (*BTree, error) {
+	return NewBTreeFromSortedFill(t, items, 1)
+}
+
+// NewBTreeFromSortedFill is like NewBTreeFromSorted except that nodes are
+// filled to about fill of their 2t-1 items rather than all of them. A fully
+// packed tree is the smallest and fastest to search, which suits read-only
+// data, but the first insert into any of its nodes splits it. Leaving
+// headroom, e.g. with a fill of 0.7, lets the tree absorb later inserts
+// without splitting at the cost of more nodes. Nodes never drop below the
+// t-1 items the invariants require, so fills below (t-1)/(2t-1) all produce
+// the same, sparsest tree.
+//
+// An error is returned if t is invalid, fill is not in (0, 1] or items is not
+// sorted or contains duplicates. Like Insert it panics if items holds NegInf
+// or PosInf.
+func NewBTreeFromSortedFill(t int, items []Item, fill float64) (*BTree, error) {
+	if err := checkDegree(t); err != nil {
+		return nil, err
+	}
+	if !(fill > 0 && fill <= 1) {
+		return nil, fmt.Errorf("invalid fill factor %v, must be in (0, 1]", fill)
+	}
+	for i, item := range items {
+		checkInsertable(item)
+		if i == 0 {
+			continue
+		}
+		if err := checkAscending(items[i-1], item); err != nil {
+			return nil, err
+		}
+	}
+	return &BTree{
+		root: buildFromSorted(t, nil, items, fill),
+		t:    t,
+		len:  len(items),
+		cmp:  compareItems,
+	}, nil
+}
+
+// checkAscending returns an error unless prev is strictly less than item.
+func checkAscending(prev, item Item) error {
+	switch compareItems(item, prev) {
+	case equal:
+		return fmt.Errorf("%w: %v, %v", ErrDuplicate, prev, item)
+	case lessThan:
+		return fmt.Errorf("%w: %v comes before %v", ErrOutOfOrder, prev, item)
+	}
+	return nil
+}
+
+// Builder is the streaming counterpart to NewBTreeFromSorted: items are fed
+// in strictly ascending order one Add at a time and Finish assembles them into
+// a densely packed tree in O(N), skipping the splits of repeated Inserts.
+type Builder struct {
+	t     int
+	items []Item
+}
+
+// NewBuilder creates a Builder for a tree with minimum degree t, an error is
+// returned if t is invalid.
+func NewBuilder(t int) (*Builder, error) {
+	if err := checkDegree(t); err != nil {
+		return nil, err
+	}
+	return &Builder{t: t}, nil
+}
+
+// Add appends item to the tree being built. It returns an error, leaving the
+// builder unchanged, if item is not greater than the previously added item,
+// and like Insert panics if item is NegInf or PosInf.
+func (bd *Builder) Add(item Item) error {
+	checkInsertable(item)
+	if len(bd.items) > 0 {
+		if err := checkAscending(bd.items[len(bd.items)-1], item); err != nil {
+			return err
+		}
+	}
+	bd.items = append(bd.items, item)
+	return nil
+}
+
+// Finish returns a tree holding every item added so far and resets the
+// builder so that it can be used to build another tree.
+func (bd *Builder) Finish() *BTree {
+	items := bd.items
+	bd.items = nil
+	return &BTree{
+		root: buildFromSorted(bd.t, nil, items, 1),
+		t:    bd.t,
+		len:  len(items),
+		cmp:  compareItems,
+	}
+}
+
+// buildFromSorted assembles the nodes of a btree over sorted items, filling
+// them to about fill of their capacity, and returns the root. The nodes come
+// from pool, so a compact tree keeps its leaf mode, each leaf reserving room
+// for its items as an insert would.
+func buildFromSorted(t int, pool *nodePool, items []Item, fill float64) *node {
+	// nodes aim for per-1 items, i.e. per children for internal nodes
+	per := int(fill*float64(2*t-1)+0.5) + 1
+	if per < t {
+		per = t
+	}
+
+	// each leaf together with the separator after it takes up between t and
+	// 2t items, so the N items plus a missing separator after the last leaf
+	// are divided among k leaves. Spreading the items evenly across them
+	// keeps every leaf within bounds.
+	k := groups(len(items)+1, per, t)
+	nodes := make([]*node, 0, k)
+	seps := make([]Item, 0, k-1)
+	m := len(items) - (k - 1) // items that go into leaves
+	pos := 0
+	for j := 0; j < k; j++ {
+		cnt := m / k
+		if j < m%k {
+			cnt++
+		}
+		leaf := pool.newNode(t, true)
+		leaf.reserve(t, cnt)
+		copy(leaf.items, items[pos:pos+cnt])
+		leaf.n = cnt
+		leaf.size = cnt
+		pos += cnt
+		nodes = append(nodes, leaf)
+		if j < k-1 {
+			seps = append(seps, items[pos])
+			pos++
+		}
+	}
+
+	// build each level above from the nodes and separators of the level below,
+	// seps[i] being the separator between nodes[i] and nodes[i+1]
+	for len(nodes) > 1 {
+		k := len(nodes)
+		p := groups(k, per, t) // parents of between t and 2t children
+		parents := make([]*node, 0, p)
+		upper := make([]Item, 0, p-1)
+		ci := 0
+		for j := 0; j < p; j++ {
+			cnt := k / p
+			if j < k%p {
+				cnt++
+			}
+			x := pool.newNode(t, false)
+			copy(x.children, nodes[ci:ci+cnt])
+			copy(x.items, seps[ci:ci+cnt-1])
+			x.n = cnt - 1
+			x.size = x.computeSize()
+			ci += cnt
+			parents = append(parents, x)
+			if j < p-1 {
+				upper = append(upper, seps[ci-1])
+			}
+		}
+		nodes, seps = parents, upper
+	}
+	return nodes[0]
+}
+
+// groups returns how many groups to divide x things into so that each group,
+// with the things spread evenly, gets about per of them and between t and 2t.
+// A single group may get fewer than t, since it ends up in the root.
+func groups(x, per, t int) int {
+	k := (x + per - 1) / per
+	if lo := (x + 2*t - 1) / (2 * t); k < lo {
+		k = lo
+	}
+	if hi := x / t; k > hi {
+		k = hi
+	}
+	if k < 1 {
+		k = 1
+	}
+	return k
+}
+
+// InsertMany inserts every item in items, as if by calling Insert on each in
+// turn, and reports how many were new and how many replaced an existing item.
+// The batch is sorted and deduplicated first (the last of several equal items
+// wins, as with repeated Inserts) so that consecutive inserts land next to each
+// other, which improves locality and reduces split thrashing. An empty tree is
+// bulk loaded from the sorted batch in O(N) instead. items itself is left
+// untouched.
+func (b *BTree) InsertMany(items []Item) (added, replaced int) {
+	// sort positions rather than items so that equal items keep their order
+	// without paying for a stable sort
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		switch b.cmp(items[order[i]], items[order[j]]) {
+		case lessThan:
+			return true
+		case equal:
+			return order[i] < order[j]
+		}
+		return false
+	})
+	sorted := make([]Item, 0, len(items))
+	for k, i := range order {
+		// in a set keep only the last of each run of equal items
+		if !b.multi && k+1 < len(order) && b.cmp(items[i], items[order[k+1]]) == equal {
+			continue
+		}
+		checkInsertable(items[i])
+		sorted = append(sorted, items[i])
+	}
+
+	if b.len == 0 {
+		b.mods++
+		b.root = buildFromSorted(b.t, b.pool, sorted, 1)
+		for _, item := range sorted {
+			b.bloomAdd(item)
+		}
+		setGen(b.root, b.gen)
+		b.len = len(sorted)
+		return len(sorted), 0
+	}
+	for _, item := range sorted {
+		if b.Insert(item) == nil {
+			added++
+		} else {
+			replaced++
+		}
+	}
+	return added, replaced
+}
+
+// Compact repacks the tree in place as densely as the invariants allow, e.g.
+// after many deletes have left lots of nodes close to minimally filled. The
+// items are dumped in order and bulk loaded into fresh nodes in O(N), and the
+// no. of nodes before and after is returned. Only the node structure
+// changes, not the items or the tree's settings, though a Bloom filter is
+// rebuilt to forget deleted items, see NewBTreeWithBloom.
+func (b *BTree) Compact() (before, after int) {
+	before = b.Stats().Nodes
+	b.mods++
+	b.root = buildFromSorted(b.t, b.pool, b.ToSlice(), 1)
+	setGen(b.root, b.gen)
+	b.rebuildBloom()
+	return before, b.Stats().Nodes
+}
+
+// WithDegree returns a copy of the tree rebuilt with minimum degree newT, for
+// instance after profiling shows another node size suits the workload better.
+// The items are dumped in order and bulk loaded in O(N), and the copy keeps
+// the tree's other settings. b is left unchanged. WithDegree panics if newT is
+// not a valid minimum degree.
+func (b *BTree) WithDegree(newT int) *BTree {
+	if err := checkDegree(newT); err != nil {
+		panic(err)
+	}
+	c := *b
+	c.t = newT
+	c.pool = b.pool.withDegree(newT)
+	c.root = buildFromSorted(newT, c.pool, b.ToSlice(), 1)
+	setGen(c.root, c.gen)
+	if b.bloom != nil {
+		c.bloom = b.bloom.copy()
+	}
+	return &c
+}
+
+// setGen marks every node in the subtree rooted at n as owned by gen.
+func setGen(n *node, gen uint64) {
+	n.gen = gen
+	if !n.isLeaf {
+		for i := 0; i <= n.n; i++ {
+			setGen(n.children[i], gen)
+		}
+	}
+}
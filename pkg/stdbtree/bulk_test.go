@@ -0,0 +1,299 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBTreeFromSorted(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+
+	for T := 2; T <= 6; T++ {
+		for N := 0; N <= 300; N++ {
+			testInfo := fmt.Sprintf("[seedVal = %d, T = %d, N = %d]", seedVal, T, N)
+			var items []Item
+			for i := 0; i < N; i++ {
+				items = append(items, numItem(i))
+			}
+			b, err := NewBTreeFromSorted(T, items)
+			require.NoError(t, err, testInfo)
+			require.NoError(t, checkInvariances(b, N), testInfo)
+
+			it := b.Iterator()
+			for i := 0; i < N; i++ {
+				require.True(t, it.Next(), testInfo)
+				require.Equal(t, numItem(i), it.Item(), testInfo)
+			}
+			require.False(t, it.Next(), testInfo)
+		}
+	}
+
+	// the bulk loaded tree is fully usable afterwards
+	T := rand.Intn(19) + 2 // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T)
+	var items []Item
+	for i := 0; i < 1000; i += 2 {
+		items = append(items, numItem(i))
+	}
+	b, err := NewBTreeFromSorted(T, items)
+	require.NoError(t, err, testInfo)
+	for i := 1; i < 1000; i += 2 {
+		require.Nil(t, b.Insert(numItem(i)), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, 1000), testInfo)
+	for i := 0; i < 1000; i += 3 {
+		require.NotNil(t, b.Delete(numItem(i)), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, 1000-334), testInfo)
+
+	// invalid degree, unsorted and duplicate-containing input are rejected
+	_, err = NewBTreeFromSorted(1, nil)
+	require.Error(t, err)
+	_, err = NewBTreeFromSorted(3, []Item{numItem(1), numItem(3), numItem(2)})
+	require.Error(t, err)
+	_, err = NewBTreeFromSorted(3, []Item{numItem(1), numItem(2), numItem(2)})
+	require.Error(t, err)
+}
+
+func TestNewBTreeFromSortedFill(t *testing.T) {
+	for T := 2; T <= 6; T++ {
+		for _, fill := range []float64{0.01, 0.3, 0.5, 0.7, 0.9, 1} {
+			for N := 0; N <= 300; N++ {
+				testInfo := fmt.Sprintf("[T = %d, fill = %v, N = %d]", T, fill, N)
+				items := make([]Item, 0, N)
+				for i := 0; i < N; i++ {
+					items = append(items, numItem(i))
+				}
+				b, err := NewBTreeFromSortedFill(T, items, fill)
+				require.NoError(t, err, testInfo)
+				require.NoError(t, checkInvariances(b, N), testInfo)
+				require.Equal(t, items, b.ToSlice(), testInfo)
+			}
+		}
+	}
+
+	// headroom is left for inserts
+	T := 10
+	var items []Item
+	for i := 0; i < 10000; i++ {
+		items = append(items, numItem(2*i))
+	}
+	packed, err := NewBTreeFromSorted(T, items)
+	require.NoError(t, err)
+	sparse, err := NewBTreeFromSortedFill(T, items, 0.7)
+	require.NoError(t, err)
+	require.InDelta(t, 1.0, packed.Stats().FillFactor, 0.01)
+	require.InDelta(t, 0.7, sparse.Stats().FillFactor, 0.05)
+	require.Greater(t, sparse.Stats().Nodes, packed.Stats().Nodes)
+	before := sparse.Stats().Nodes
+	sparse.Insert(numItem(1))
+	require.Equal(t, before, sparse.Stats().Nodes, "insert into a node with headroom need not split")
+
+	// fill must be in (0, 1]
+	for _, fill := range []float64{0, -0.5, 1.01} {
+		_, err := NewBTreeFromSortedFill(T, items, fill)
+		require.Error(t, err, "fill = %v", fill)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	_, err := NewBuilder(1)
+	require.Error(t, err)
+
+	bd, err := NewBuilder(T)
+	require.NoError(t, err, testInfo)
+	require.NoError(t, checkInvariances(bd.Finish(), 0), testInfo)
+
+	for i := 0; i < N; i++ {
+		require.NoError(t, bd.Add(numItem(2*i)), testInfo)
+		// out of order and duplicate items are rejected without effect
+		require.Error(t, bd.Add(numItem(2*i-1)), testInfo)
+		require.Error(t, bd.Add(numItem(2*i)), testInfo)
+	}
+	b := bd.Finish()
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	for i, item := range b.ToSlice() {
+		require.Equal(t, numItem(2*i), item, testInfo)
+	}
+
+	// packed the same way as the non-streaming bulk loader
+	expected, err := NewBTreeFromSorted(T, b.ToSlice())
+	require.NoError(t, err, testInfo)
+	require.Equal(t, expected.LevelOrder(), b.LevelOrder(), testInfo)
+
+	// the finished tree is independent of the builder, which starts afresh
+	require.NoError(t, bd.Add(numItem(0)), testInfo)
+	require.NoError(t, checkInvariances(bd.Finish(), 1), testInfo)
+	require.NoError(t, checkInvariances(b, N), testInfo)
+}
+
+func TestInsertMany(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for i := 0; i < N; i += 2 {
+		b.Insert(&idItem{key: i})
+	}
+
+	// a batch with duplicates both within itself and against the tree
+	var batch []Item
+	for i := 0; i < 2*N; i++ {
+		batch = append(batch, &idItem{key: rand.Intn(N), id: i})
+	}
+	last := make(map[int]int) // key -> id of the last item with that key
+	for _, item := range batch {
+		last[item.(*idItem).key] = item.(*idItem).id
+	}
+	var wantAdded, wantReplaced int
+	for key := range last {
+		if key%2 == 0 {
+			wantReplaced++
+		} else {
+			wantAdded++
+		}
+	}
+
+	added, replaced := b.InsertMany(batch)
+	require.Equal(t, wantAdded, added, testInfo)
+	require.Equal(t, wantReplaced, replaced, testInfo)
+	require.NoError(t, checkInvariances(b, N/2+wantAdded), testInfo)
+	for key, id := range last {
+		require.Equal(t, id, b.Search(&idItem{key: key}).(*idItem).id, testInfo)
+	}
+
+	// an empty tree is bulk loaded straight from the batch
+	b = NewBTree(T)
+	added, replaced = b.InsertMany(batch)
+	require.Equal(t, len(last), added, testInfo)
+	require.Equal(t, 0, replaced, testInfo)
+	require.NoError(t, checkInvariances(b, len(last)), testInfo)
+	for key, id := range last {
+		require.Equal(t, id, b.Search(&idItem{key: key}).(*idItem).id, testInfo)
+	}
+	b.InsertMany(batch)
+	require.NoError(t, checkInvariances(b, len(last)), testInfo)
+}
+
+func BenchmarkInsertMany(b *testing.B) {
+	var items []Item
+	for _, num := range rand.Perm(1000000) {
+		items = append(items, numItem(num))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewBTree(32).InsertMany(items)
+	}
+}
+
+func BenchmarkInsertEach(b *testing.B) {
+	var items []Item
+	for _, num := range rand.Perm(1000000) {
+		items = append(items, numItem(num))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewBTree(32)
+		for _, item := range items {
+			tree.Insert(item)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 3000
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	before, after := b.Compact()
+	require.Equal(t, 1, before, testInfo)
+	require.Equal(t, 1, after, testInfo)
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	// deleting the odd items leaves the nodes sparser
+	for i := 1; i < N; i += 2 {
+		b.Delete(numItem(i))
+	}
+	items := b.ToSlice()
+	nodes := b.Stats().Nodes
+	before, after = b.Compact()
+	require.Equal(t, nodes, before, testInfo)
+	require.Equal(t, b.Stats().Nodes, after, testInfo)
+	require.Less(t, after, before, testInfo)
+	require.NoError(t, checkInvariances(b, N/2), testInfo)
+	require.Equal(t, items, b.ToSlice(), testInfo)
+	require.InDelta(t, 1.0, b.Stats().FillFactor, 0.1, testInfo)
+
+	// still fully usable
+	for i := 1; i < N; i += 2 {
+		require.Nil(t, b.Insert(numItem(i)), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+}
+
+func TestWithDegree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 3000
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.Panics(t, func() { NewBTree(T).WithDegree(1) }, testInfo)
+	require.NoError(t, checkInvariances(NewBTree(T).WithDegree(T+1), 0), testInfo)
+
+	trees := map[string]*BTree{
+		"plain":    NewBTree(T),
+		"pool":     NewBTreeWithPool(T),
+		"capacity": NewBTreeWithCapacity(T, 2*T-1),
+		"multi":    NewMultiBTree(T),
+		"bloom":    NewBTreeWithBloom(T, N, hashNumItem),
+	}
+	for name, b := range trees {
+		for _, i := range rand.Perm(N) {
+			b.Insert(numItem(i / 2))
+		}
+		items := b.ToSlice()
+		for _, newT := range []int{2, T, 2 * T, 64} {
+			info := fmt.Sprintf("%s %s newT = %d", testInfo, name, newT)
+			c := b.WithDegree(newT)
+			require.Equal(t, newT, c.t, info)
+			require.NoError(t, checkInvariances(c, len(items)), info)
+			require.Equal(t, items, c.ToSlice(), info)
+
+			// the copy keeps the tree's settings and is independent of it
+			for i := 0; i < N; i++ {
+				c.Insert(numItem(N + i))
+				c.Delete(numItem(i / 2))
+			}
+			c.Insert(numItem(-1))
+			c.Insert(numItem(-1))
+			if b.multi {
+				require.Equal(t, 2, c.Count(numItem(-1)), info)
+			}
+			require.NoError(t, checkInvariances(c, N+c.Count(numItem(-1))), info)
+			require.Equal(t, items, b.ToSlice(), info)
+			require.NoError(t, checkInvariances(b, len(items)), info)
+		}
+	}
+	require.True(t, trees["bloom"].WithDegree(T).Contains(numItem(0)), testInfo)
+}
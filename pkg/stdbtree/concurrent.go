@@ -0,0 +1,60 @@
+package stdbtree
+
+import "sync"
+
+// ConcurrentBTree wraps a BTree with a sync.RWMutex so that it can be used by
+// many concurrent readers alongside writers. Readers share the lock, writers
+// hold it exclusively. Use a plain BTree when there is only a single goroutine
+// to avoid the locking overhead.
+type ConcurrentBTree struct {
+	mu   sync.RWMutex
+	tree *BTree
+}
+
+// NewConcurrentBTree creates an empty ConcurrentBTree with minimum degree t,
+// see NewBTree for the constraints on t.
+func NewConcurrentBTree(t int) *ConcurrentBTree {
+	return &ConcurrentBTree{tree: NewBTree(t)}
+}
+
+// Insert adds item to the tree, see BTree.Insert.
+func (c *ConcurrentBTree) Insert(item Item) Item {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.Insert(item)
+}
+
+// Delete removes the item equal to item from the tree, see BTree.Delete.
+func (c *ConcurrentBTree) Delete(item Item) Item {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.Delete(item)
+}
+
+// Search returns the stored item equal to item, see BTree.Search.
+func (c *ConcurrentBTree) Search(item Item) Item {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.Search(item)
+}
+
+// RangeScan returns the items in [lo, hi], see BTree.RangeScan.
+func (c *ConcurrentBTree) RangeScan(lo, hi Item) []Item {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.RangeScan(lo, hi)
+}
+
+// ToSlice returns every item in ascending order, see BTree.ToSlice.
+func (c *ConcurrentBTree) ToSlice() []Item {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.ToSlice()
+}
+
+// Len returns the number of items in the tree.
+func (c *ConcurrentBTree) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.Len()
+}
@@ -0,0 +1,69 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// run with -race to check for data races
+func TestConcurrentBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 1000
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	c := NewConcurrentBTree(T)
+	nums := rand.Perm(N)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for _, num := range nums {
+			c.Insert(numItem(num))
+		}
+	}()
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// an item once seen stays visible since nothing is deleted
+				num := numItem(rand.Intn(N))
+				if c.Search(num) != nil && c.Search(num) == nil {
+					t.Errorf("%s item %v disappeared", testInfo, num)
+					return
+				}
+				items := c.RangeScan(num, num+10)
+				for i := 1; i < len(items); i++ {
+					if items[i-1].Compare(items[i]) != lessThan {
+						t.Errorf("%s range scan out of order: %v", testInfo, items)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, N, c.Len(), testInfo)
+	require.NoError(t, checkInvariances(c.tree, N), testInfo)
+	items := c.ToSlice()
+	for i := range items {
+		require.Equal(t, numItem(i), items[i], testInfo)
+	}
+}
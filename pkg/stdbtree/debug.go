@@ -0,0 +1,120 @@
+package stdbtree
+
+import "fmt"
+
+// SetDebug turns debug mode on or off. In debug mode every Insert, Delete and
+// their variants runs node.check over the nodes it may have touched, the
+// nodes along its path and their children, and panics at the first violation
+// found. Corruption is thus caught by the update that causes it instead of
+// at the next Validate, for the price of O(height * t) node checks per
+// update. Before descending, inserts also check the comparator along their
+// path with debugProbe, so that one which contradicts itself panics with the
+// items involved rather than quietly misplacing the new item. Only a flag is
+// tested when debug mode is off.
+func (b *BTree) SetDebug(enabled bool) {
+	b.debug = enabled
+}
+
+// debugCheck checks the root and, along the search path of each non-nil probe,
+// the children of every node, panicking at the first violation found. The
+// children are checked before descending into one so that a broken node is
+// never traversed.
+func (b *BTree) debugCheck(op string, probes ...Item) {
+	check := func(n *node, isRoot bool) {
+		if err := n.check(b.t, b.cmp, isRoot); err != nil {
+			panic(fmt.Errorf("btree %s left a broken node: %w", op, err))
+		}
+	}
+	check(b.root, true)
+	for _, probe := range probes {
+		if probe == nil {
+			continue
+		}
+		n := b.root
+		for !n.isLeaf {
+			for i := 0; i <= n.n; i++ {
+				check(n.children[i], false)
+			}
+			i, found := n.find(b.cmp, probe)
+			if found {
+				break
+			}
+			n = n.children[i]
+		}
+	}
+}
+
+// deleteProbes returns the items whose search paths cover every node a delete
+// of item may have touched. A delete descends along item's path until it
+// finds item, and if that is in an internal node goes on down to the leaf of
+// its predecessor or successor, which then takes item's place. The rest of
+// that descent is the path to the item now next to the one moved up.
+func (b *BTree) deleteProbes(item Item) []Item {
+	probes := []Item{item}
+	if pred := b.Floor(item); pred != nil {
+		probes = append(probes, b.Predecessor(pred))
+	}
+	if succ := b.Ceiling(item); succ != nil {
+		probes = append(probes, b.Successor(succ))
+	}
+	return probes
+}
+
+// relation names the outcome c of a comparison, for panic messages.
+func relation(c int) string {
+	switch c {
+	case lessThan:
+		return "less than"
+	case equal:
+		return "equal to"
+	}
+	return "greater than"
+}
+
+// consistentCmp wraps cmp so that every comparison is also made the other way
+// round, panicking unless cmp returns -1, 0 or 1 and the two answers agree.
+func consistentCmp(op string, cmp func(a, b Item) int) func(a, b Item) int {
+	return func(a, b Item) int {
+		c, r := cmp(a, b), cmp(b, a)
+		if c < lessThan || c > greaterThan {
+			panic(fmt.Sprintf("btree %s: comparing %v with %v returned %d, not -1, 0 or 1", op, a, b, c))
+		}
+		if r != -c {
+			panic(fmt.Sprintf("btree %s: inconsistent comparator, %v is %s %v but %v is not %s %v",
+				op, a, relation(c), b, b, relation(-c), a))
+		}
+		return c
+	}
+}
+
+// debugProbe walks down the path an insert of item takes, comparing item,
+// through consistentCmp, with every item of each node along the way. Since a
+// node's items ascend, item must be greater than some first of them, then
+// equal to some and less than the rest; it panics at the first node where it
+// is not, as an insert's binary search would then place item arbitrarily.
+func (b *BTree) debugProbe(op string, item Item) {
+	cmp := consistentCmp(op, b.cmp)
+	n := b.root
+	for {
+		i, found := n.n, false
+		prev := greaterThan
+		for j := 0; j < n.n; j++ {
+			c := cmp(item, n.items[j])
+			if c > prev {
+				panic(fmt.Sprintf("btree %s: %v is %s %v but %s the greater %v, "+
+					"an inconsistent comparator or out of order node",
+					op, item, relation(prev), n.items[j-1], relation(c), n.items[j]))
+			}
+			// the index find would return, or in a multiset the upperBound
+			// insertMulti goes by
+			if i == n.n && (c == lessThan || (c == equal && !b.multi)) {
+				i, found = j, c == equal
+			}
+			prev = c
+		}
+		if found || n.isLeaf {
+			return
+		}
+		n = n.children[i]
+	}
+}
@@ -0,0 +1,150 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugMode(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// a correct tree passes every check
+	b := NewBTree(T)
+	b.SetDebug(true)
+	var f Finger
+	require.NotPanics(t, func() {
+		for i := 0; i < 10*N; i++ {
+			num := numItem(rand.Intn(N))
+			switch rand.Intn(6) {
+			case 0:
+				b.Delete(num)
+			case 1:
+				b.DeleteMin()
+			case 2:
+				b.DeleteMax()
+			case 3:
+				b.GetOrInsert(num)
+			case 4:
+				b.InsertWithFinger(&f, num)
+			default:
+				b.Insert(num)
+			}
+		}
+	}, testInfo)
+	require.NoError(t, checkInvariances(b, b.Len()), testInfo)
+
+	// store even numbers and swap two items in a leaf, an update landing
+	// there panics right away; the leaf must not be full as a split would
+	// carry the damage up beyond what a single node check can see
+	newCorrupted := func() (*BTree, numItem) {
+		b := NewBTree(T)
+		for _, i := range rand.Perm(N) {
+			b.Insert(numItem(2 * i))
+		}
+		for i := 0; i < N; i++ {
+			path := b.searchPath(numItem(2 * i))
+			if l := path[len(path)-1]; l.isLeaf && l.n >= 2 && l.n < 2*T-1 {
+				l.items[0], l.items[1] = l.items[1], l.items[0]
+				b.SetDebug(true)
+				return b, l.items[1].(numItem) + 1
+			}
+		}
+		panic("no leaf with two items")
+	}
+	b, probe := newCorrupted()
+	require.Panics(t, func() { b.Insert(probe) }, testInfo)
+	b, probe = newCorrupted()
+	require.Panics(t, func() { b.Delete(probe) }, testInfo)
+
+	// with it off nothing is checked
+	b, probe = newCorrupted()
+	b.SetDebug(false)
+	require.NotPanics(t, func() { b.Insert(probe) }, testInfo)
+	require.Error(t, b.Validate(), testInfo)
+}
+
+func TestDebugComparator(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// bug turns on a comparator that, for 1000, answers differently
+	// depending on which side it is on
+	bug := false
+	asymmetric := func(a, b Item) int {
+		if bug && a == numItem(1000) {
+			return lessThan
+		}
+		return compareItems(a, b)
+	}
+	b := NewBTreeFunc(T, asymmetric)
+	b.SetDebug(true)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	bug = true
+	require.PanicsWithValue(t,
+		fmt.Sprintf("btree Insert: inconsistent comparator, 1000 is less than %v but %v is not greater than 1000", b.root.items[0], b.root.items[0]),
+		func() { b.Insert(numItem(1000)) }, testInfo)
+	require.Panics(t, func() { b.GetOrInsert(numItem(1000)) }, testInfo)
+
+	// one that returns values other than -1, 0 or 1
+	b = NewBTreeFunc(T, func(a, b Item) int { return 2 * compareItems(a, b) })
+	b.SetDebug(true)
+	require.NotPanics(t, func() { b.Insert(numItem(1)) }, testInfo)
+	require.PanicsWithValue(t, "btree Insert: comparing 2 with 1 returned 2, not -1, 0 or 1",
+		func() { b.Insert(numItem(2)) }, testInfo)
+
+	// one that is symmetric but places 10 above 1 and 3 yet below 2 between them;
+	// with debug mode off the tree takes 10 in silently
+	nonMonotonic := func(a, b Item) int {
+		switch {
+		case a == numItem(10) && b == numItem(2):
+			return lessThan
+		case a == numItem(2) && b == numItem(10):
+			return greaterThan
+		}
+		return compareItems(a, b)
+	}
+	for _, debug := range []bool{true, false} {
+		b = NewBTreeFunc(2, nonMonotonic)
+		b.SetDebug(debug)
+		b.root = buildNode(2, []int{1, 2, 3})
+		insert := func() { b.Insert(numItem(10)) }
+		if debug {
+			require.PanicsWithValue(t, "btree Insert: 10 is less than 2 but greater than the greater 3, "+
+				"an inconsistent comparator or out of order node", insert)
+		} else {
+			require.NotPanics(t, insert)
+		}
+	}
+
+	// in a multiset an insert of 5 goes past the equal root into the right
+	// child, which is where a comparator placing 5 above 7 is caught
+	fiveAboveSeven := func(a, b Item) int {
+		switch {
+		case a == numItem(5) && b == numItem(7):
+			return greaterThan
+		case a == numItem(7) && b == numItem(5):
+			return lessThan
+		}
+		return compareItems(a, b)
+	}
+	b = NewBTreeFunc(2, fiveAboveSeven)
+	b.multi = true
+	b.SetDebug(true)
+	b.root = buildNode(2, []int{5}, buildNode(2, []int{1, 2}), buildNode(2, []int{6, 7, 8}))
+	b.len = b.root.size
+	require.PanicsWithValue(t, "btree Insert: 5 is less than 6 but greater than the greater 7, "+
+		"an inconsistent comparator or out of order node", func() { b.Insert(numItem(5)) })
+}
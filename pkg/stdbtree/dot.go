@@ -0,0 +1,50 @@
+package stdbtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ToDOT writes the tree to w as a Graphviz DOT graph. Every node is drawn as a
+// record of its items, with a port between each pair of items from which the
+// edge to the corresponding child leaves. Render it with e.g.
+// `dot -Tsvg tree.dot > tree.svg`.
+func (b *BTree) ToDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph btree {")
+	fmt.Fprintln(bw, "\tnode [shape=record, height=.1];")
+
+	var id int
+	var writeNode func(n *node) int
+	writeNode = func(n *node) int {
+		nodeID := id
+		id++
+		var fields []string
+		for i := 0; i < n.n; i++ {
+			fields = append(fields, fmt.Sprintf("<f%d> ", i), dotEscape(fmt.Sprint(n.items[i])))
+		}
+		fields = append(fields, fmt.Sprintf("<f%d> ", n.n))
+		fmt.Fprintf(bw, "\tnode%d [label=\"%s\"];\n", nodeID, strings.Join(fields, "|"))
+		if !n.isLeaf {
+			for i := 0; i <= n.n; i++ {
+				childID := writeNode(n.children[i])
+				fmt.Fprintf(bw, "\tnode%d:f%d -> node%d;\n", nodeID, i, childID)
+			}
+		}
+		return nodeID
+	}
+	writeNode(b.root)
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotEscape escapes the characters that have a special meaning inside a DOT
+// record label.
+func dotEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `<`, `\<`, `>`, `\>`,
+	).Replace(s)
+}
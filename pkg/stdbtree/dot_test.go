@@ -0,0 +1,45 @@
+package stdbtree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToDOT(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	var nodes int
+	var countNodes func(n *node)
+	countNodes = func(n *node) {
+		nodes++
+		if !n.isLeaf {
+			for i := 0; i <= n.n; i++ {
+				countNodes(n.children[i])
+			}
+		}
+	}
+	countNodes(b.root)
+
+	var buf bytes.Buffer
+	require.NoError(t, b.ToDOT(&buf), testInfo)
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "digraph btree {"), testInfo)
+	require.Equal(t, nodes, strings.Count(out, "[label="), testInfo)
+	// every node but the root has exactly one incoming edge
+	require.Equal(t, nodes-1, strings.Count(out, "->"), testInfo)
+}
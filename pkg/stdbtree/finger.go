@@ -0,0 +1,92 @@
+package stdbtree
+
+// Finger caches the path to the leaf of the last insert made through it, so
+// that a following insert of a nearby item, such as the next key in an
+// increasing sequence, can go straight to that leaf instead of descending
+// from the root. The zero value is an empty finger ready to use. A Finger
+// belongs to one tree at a time and is only trusted for as long as the tree has
+// not been modified by anything other than inserts through the same finger.
+type Finger struct {
+	tree   *BTree
+	mods   uint64
+	gen    uint64
+	path   []*node // from the root down to the leaf
+	lo, hi Item    // separators bounding the leaf, nil where open
+}
+
+// InsertWithFinger is like Insert but first tries the leaf cached in f. If the
+// tree is unchanged since f was last used on it, the leaf has room and item
+// falls strictly between the separators bounding it, item is inserted there
+// directly. Otherwise it falls back to a regular insert from the root and
+// points f at the leaf item went to. In a multiset tree f is never used.
+func (b *BTree) InsertWithFinger(f *Finger, item Item) (prev Item) {
+	if b.multi {
+		return b.Insert(item)
+	}
+	if f.covers(b, item) {
+		checkInsertable(item)
+		if b.debug {
+			b.debugProbe("InsertWithFinger", item)
+		}
+		b.bloomAdd(item)
+		b.mods++
+		leaf := f.path[len(f.path)-1]
+		prev = leaf.insertLeaf(b.t, b.cmp, item, true)
+		if prev == nil {
+			for _, n := range f.path[:len(f.path)-1] {
+				n.size++
+			}
+			b.len++
+		}
+		f.mods = b.mods
+		if b.debug {
+			b.debugCheck("InsertWithFinger", item)
+		}
+		return prev
+	}
+	prev = b.Insert(item)
+	f.point(b, item)
+	return prev
+}
+
+// covers reports whether item can go straight into f's leaf of b.
+func (f *Finger) covers(b *BTree, item Item) bool {
+	if f.tree != b || f.mods != b.mods || f.gen != b.gen {
+		return false
+	}
+	if f.path[len(f.path)-1].n == 2*b.t-1 {
+		return false
+	}
+	return (f.lo == nil || b.cmp(item, f.lo) == greaterThan) &&
+		(f.hi == nil || b.cmp(item, f.hi) == lessThan)
+}
+
+// point sets f to the path from b's root to the leaf holding item, or clears f
+// if item ended up in an internal node or the path holds a node b does not
+// own.
+func (f *Finger) point(b *BTree, item Item) {
+	*f = Finger{path: f.path[:0]}
+	n := b.root
+	for {
+		if n.gen != b.gen {
+			return
+		}
+		f.path = append(f.path, n)
+		i, found := n.find(b.cmp, item)
+		if found && !n.isLeaf {
+			return
+		}
+		if n.isLeaf {
+			break
+		}
+		// the separators on either side of the child narrow the bounds
+		if i > 0 {
+			f.lo = n.items[i-1]
+		}
+		if i < n.n {
+			f.hi = n.items[i]
+		}
+		n = n.children[i]
+	}
+	f.tree, f.mods, f.gen = b, b.mods, b.gen
+}
@@ -0,0 +1,87 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertWithFinger(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// ascending keys, the case the finger is meant for
+	b := NewBTree(T)
+	var f Finger
+	for i := 0; i < N; i++ {
+		require.Nil(t, b.InsertWithFinger(&f, numItem(i)), testInfo)
+		require.NoError(t, checkInvariances(b, i+1), testInfo)
+	}
+	require.Equal(t, numItem(N/2), b.InsertWithFinger(&f, numItem(N/2)), testInfo)
+	require.NoError(t, checkInvariances(b, N), testInfo)
+
+	// nearby keys interleaved with other modifications, which the finger
+	// must notice
+	b = NewBTree(T)
+	f = Finger{}
+	present := make(map[int]bool)
+	last := 0
+	for i := 0; i < 10*N; i++ {
+		switch rand.Intn(4) {
+		case 0:
+			num := rand.Intn(N)
+			require.Equal(t, present[num], b.Delete(numItem(num)) != nil, testInfo)
+			delete(present, num)
+		case 1:
+			num := rand.Intn(N)
+			b.Insert(numItem(num))
+			present[num] = true
+		default:
+			last = (last + rand.Intn(5) - 1 + N) % N
+			require.Equal(t, present[last], b.InsertWithFinger(&f, numItem(last)) != nil, testInfo)
+			present[last] = true
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+
+	// a finger used on another tree is not trusted there
+	c := b.Clone()
+	require.Nil(t, c.InsertWithFinger(&f, numItem(N)), testInfo)
+	require.NoError(t, checkInvariances(c, len(present)+1), testInfo)
+	require.NoError(t, checkInvariances(b, len(present)), testInfo)
+}
+
+// BenchmarkAppendSorted and BenchmarkAppendSortedFinger compare appending 1M
+// ascending keys with and without a finger.
+func benchmarkAppendSorted(b *testing.B, useFinger bool) {
+	items := make([]Item, 1000000)
+	for i := range items {
+		items[i] = numItem(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewBTree(32)
+		var f Finger
+		for _, item := range items {
+			if useFinger {
+				tree.InsertWithFinger(&f, item)
+			} else {
+				tree.Insert(item)
+			}
+		}
+	}
+}
+
+func BenchmarkAppendSorted(b *testing.B) {
+	benchmarkAppendSorted(b, false)
+}
+
+func BenchmarkAppendSortedFinger(b *testing.B) {
+	benchmarkAppendSorted(b, true)
+}
@@ -0,0 +1,389 @@
+package stdbtree
+
+// genericNode mirrors node but stores items of type T inline rather than as
+// item interface values, so keys such as ints need no boxing. For small keys
+// this also keeps a node's items in one contiguous block of memory, where the
+// interface layout holds pointers off to separately allocated values, and a
+// search no longer chases a pointer per comparison. It pays off once the tree
+// outgrows the CPU caches, see BenchmarkSearchGeneric; for keys that are
+// themselves large or pointer-like, such as strings, the gain is small.
+type genericNode[T any] struct {
+	isLeaf   bool
+	n        int // tracks no. of items in a node
+	items    []T
+	children []*genericNode[T]
+}
+
+func newGenericNode[T any](t int, isLeaf bool) *genericNode[T] {
+	items := make([]T, 2*t-1)
+	var children []*genericNode[T] = nil
+	if !isLeaf { // if is internal
+		children = make([]*genericNode[T], 2*t)
+	}
+	return &genericNode[T]{
+		isLeaf:   isLeaf,
+		items:    items,
+		children: children,
+	}
+}
+
+func (n *genericNode[T]) search(cmp func(a, b T) int, item T) (found T, ok bool) {
+	for {
+		i, hit := n.find(cmp, item)
+		if hit {
+			return n.items[i], true
+		}
+		if n.isLeaf {
+			return
+		}
+		n = n.children[i]
+	}
+}
+
+func (n *genericNode[T]) insertLeaf(cmp func(a, b T) int, newItem T) (prev T, replaced bool) {
+	var i int
+loop:
+	for i = 0; i < n.n; i++ {
+		curr := n.items[i]
+		switch cmp(newItem, curr) {
+		case equal:
+			prev, replaced = curr, true
+			break loop
+		case lessThan:
+			copy(n.items[i+1:], n.items[i:])
+			break loop
+		}
+	}
+	n.items[i] = newItem
+	if !replaced { // i.e. is fresh insert
+		n.n++
+	}
+	return
+}
+
+func (n *genericNode[T]) insert(t int, cmp func(a, b T) int, newItem T) (prev T, replaced bool) {
+	if n.isLeaf {
+		return n.insertLeaf(cmp, newItem)
+	}
+	var i int
+loop:
+	for i = 0; i < n.n; i++ {
+		curr := n.items[i]
+		switch cmp(newItem, curr) {
+		case equal:
+			prev, replaced = curr, true
+			n.items[i] = newItem
+			return
+		case lessThan:
+			break loop
+		}
+	}
+	c := n.children[i]
+	if c.n == 2*t-1 {
+		median := n.splitChild(t, i)
+		switch cmp(newItem, median) {
+		case lessThan:
+			// go to left child
+		case equal:
+			// replace
+			prev, replaced = median, true
+			n.items[i] = newItem
+			return
+		case greaterThan:
+			// go to newly upped right child
+			c = n.children[i+1]
+		}
+	}
+	return c.insert(t, cmp, newItem)
+}
+
+func (n *genericNode[T]) splitChild(t int, i int) (median T) {
+	// let y be the ith child of node n.
+	y := n.children[i]
+	median = y.items[t-1]
+
+	// halve y and move the upper half to new node z
+	z := newGenericNode[T](t, y.isLeaf)
+	copy(z.items, y.items[t:])
+	z.n = t - 1
+	y.n = t - 1
+	if !y.isLeaf { // only internal nodes have children
+		copy(z.children, y.children[t:])
+	}
+
+	// move median item up to parent (node n)
+	copy(n.items[i+1:], n.items[i:])
+	n.items[i] = median
+	n.n++
+
+	// add z as node n's child
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = z
+	return median
+}
+
+// find returns the index of the first item in n that is not less than key,
+// and whether the item at that index is equal to key, see node.find.
+func (n *genericNode[T]) find(cmp func(a, b T) int, key T) (i int, found bool) {
+	lo, hi := 0, n.n
+	for lo < hi {
+		h := int(uint(lo+hi) >> 1)
+		switch cmp(key, n.items[h]) {
+		case greaterThan:
+			lo = h + 1
+		case equal:
+			return h, true
+		case lessThan:
+			hi = h
+		}
+	}
+	return lo, false
+}
+
+func (n *genericNode[T]) removeAt(i int) (removed T) {
+	removed = n.items[i]
+	copy(n.items[i:], n.items[i+1:n.n])
+	n.n--
+	var zero T
+	n.items[n.n] = zero
+	return removed
+}
+
+// remove deletes key from the subtree rooted at n following CLRS
+// B-Tree-Delete, see node.remove. typ selects whether key, the min or the max
+// is removed.
+func (n *genericNode[T]) remove(t int, cmp func(a, b T) int, key T, typ toRemove) (removed T, ok bool) {
+	var i int
+	var found bool
+	switch typ {
+	case removeMax:
+		if n.isLeaf {
+			return n.removeAt(n.n - 1), true
+		}
+		i = n.n
+	case removeMin:
+		if n.isLeaf {
+			return n.removeAt(0), true
+		}
+		i = 0
+	case removeItem:
+		i, found = n.find(cmp, key)
+		if n.isLeaf {
+			// case 1: key is in a leaf, or absent altogether
+			if found {
+				return n.removeAt(i), true
+			}
+			return
+		}
+	}
+
+	if found {
+		// case 2: key is in internal node n
+		y, z := n.children[i], n.children[i+1]
+		removed, ok = n.items[i], true
+		switch {
+		case y.n >= t:
+			// 2a: replace key with its predecessor
+			n.items[i], _ = y.remove(t, cmp, key, removeMax)
+		case z.n >= t:
+			// 2b: replace key with its successor
+			n.items[i], _ = z.remove(t, cmp, key, removeMin)
+		default:
+			// 2c: merge key and z into y, then delete key from y
+			n.mergeChildren(i)
+			return y.remove(t, cmp, key, removeItem)
+		}
+		return
+	}
+
+	// case 3: key, if present, is in the subtree rooted at children[i]
+	if n.children[i].n == t-1 {
+		i = n.fillChild(t, i)
+	}
+	return n.children[i].remove(t, cmp, key, typ)
+}
+
+// fillChild ensures that the ith child of n has at least t items, see
+// node.fillChild.
+func (n *genericNode[T]) fillChild(t int, i int) int {
+	var zero T
+	switch {
+	case i > 0 && n.children[i-1].n >= t:
+		// borrow from left sibling through the separator
+		c, left := n.children[i], n.children[i-1]
+		copy(c.items[1:], c.items[:c.n])
+		c.items[0] = n.items[i-1]
+		if !c.isLeaf {
+			copy(c.children[1:], c.children[:c.n+1])
+			c.children[0] = left.children[left.n]
+			left.children[left.n] = nil
+		}
+		c.n++
+		n.items[i-1] = left.items[left.n-1]
+		left.items[left.n-1] = zero
+		left.n--
+	case i < n.n && n.children[i+1].n >= t:
+		// borrow from right sibling through the separator
+		c, right := n.children[i], n.children[i+1]
+		c.items[c.n] = n.items[i]
+		if !c.isLeaf {
+			c.children[c.n+1] = right.children[0]
+			copy(right.children, right.children[1:right.n+1])
+			right.children[right.n] = nil
+		}
+		c.n++
+		n.items[i] = right.removeAt(0)
+	default:
+		if i == n.n { // rightmost child has no right sibling, merge with left
+			i--
+		}
+		n.mergeChildren(i)
+	}
+	return i
+}
+
+// mergeChildren merges the separator items[i] and the (i+1)th child into the
+// ith child, see node.mergeChildren.
+func (n *genericNode[T]) mergeChildren(i int) {
+	y, z := n.children[i], n.children[i+1]
+	y.items[y.n] = n.items[i]
+	copy(y.items[y.n+1:], z.items[:z.n])
+	if !y.isLeaf {
+		copy(y.children[y.n+1:], z.children[:z.n+1])
+	}
+	y.n += z.n + 1
+
+	// remove separator and z from n
+	copy(n.items[i:], n.items[i+1:n.n])
+	copy(n.children[i+1:], n.children[i+2:n.n+1])
+	var zero T
+	n.items[n.n-1] = zero
+	n.children[n.n] = nil
+	n.n--
+}
+
+// ascendRange calls fn in ascending order for every item x in the subtree
+// rooted at n with lo <= x <= hi, see node.ascendRange.
+func (n *genericNode[T]) ascendRange(cmp func(a, b T) int, lo, hi T, fn func(T) bool) bool {
+	i, _ := n.find(cmp, lo)
+	for ; i < n.n; i++ {
+		if !n.isLeaf {
+			if !n.children[i].ascendRange(cmp, lo, hi, fn) {
+				return false
+			}
+		}
+		if cmp(hi, n.items[i]) == lessThan {
+			return false
+		}
+		if !fn(n.items[i]) {
+			return false
+		}
+	}
+	if !n.isLeaf {
+		return n.children[n.n].ascendRange(cmp, lo, hi, fn)
+	}
+	return true
+}
+
+// genericBTree is a btree over items of type T ordered by cmp. cmp(a, b) must
+// return lessThan, equal or greaterThan according to how a compares to b.
+type genericBTree[T any] struct {
+	root *genericNode[T]
+	cmp  func(a, b T) int
+	t    int
+	len  int
+}
+
+// newGenericBTree creates a genericBTree with minimum degree t, see NewBTree
+// for the constraints on t.
+func newGenericBTree[T any](t int, cmp func(a, b T) int) *genericBTree[T] {
+	if err := checkDegree(t); err != nil {
+		panic(err)
+	}
+	x := newGenericNode[T](t, true)
+	return &genericBTree[T]{
+		t:    t,
+		cmp:  cmp,
+		root: x,
+	}
+}
+
+func (b *genericBTree[T]) search(item T) (found T, ok bool) {
+	return b.root.search(b.cmp, item)
+}
+
+func (b *genericBTree[T]) insert(item T) (prev T, replaced bool) {
+	if b.root.n == (2*b.t - 1) {
+		oldRoot := b.root
+		b.root = newGenericNode[T](b.t, false)
+		b.root.children[0] = oldRoot
+		b.root.splitChild(b.t, 0)
+	}
+	prev, replaced = b.root.insert(b.t, b.cmp, item)
+	if !replaced {
+		b.len++
+	}
+	return
+}
+
+// ascend calls fn for every item in the subtree rooted at n in ascending order,
+// returning false as soon as fn does.
+func (n *genericNode[T]) ascend(fn func(T) bool) bool {
+	for i := 0; i < n.n; i++ {
+		if !n.isLeaf && !n.children[i].ascend(fn) {
+			return false
+		}
+		if !fn(n.items[i]) {
+			return false
+		}
+	}
+	if !n.isLeaf {
+		return n.children[n.n].ascend(fn)
+	}
+	return true
+}
+
+func (b *genericBTree[T]) delete(item T) (removed T, ok bool) {
+	if b.len == 0 {
+		return
+	}
+	removed, ok = b.root.remove(b.t, b.cmp, item, removeItem)
+	if b.root.n == 0 && !b.root.isLeaf {
+		// root emptied out after a merge, shrink the tree height
+		b.root = b.root.children[0]
+	}
+	if ok {
+		b.len--
+	}
+	return
+}
+
+// min returns the smallest item, ok is unset if the tree is empty.
+func (b *genericBTree[T]) min() (min T, ok bool) {
+	n := b.root
+	for !n.isLeaf {
+		n = n.children[0]
+	}
+	if n.n == 0 {
+		return
+	}
+	return n.items[0], true
+}
+
+// max returns the largest item, ok is unset if the tree is empty.
+func (b *genericBTree[T]) max() (max T, ok bool) {
+	n := b.root
+	for !n.isLeaf {
+		n = n.children[n.n]
+	}
+	if n.n == 0 {
+		return
+	}
+	return n.items[n.n-1], true
+}
+
+// ascendRange calls fn in ascending order for every item x with lo <= x <= hi,
+// stopping as soon as fn returns false.
+func (b *genericBTree[T]) ascendRange(lo, hi T, fn func(T) bool) {
+	b.root.ascendRange(b.cmp, lo, hi, fn)
+}
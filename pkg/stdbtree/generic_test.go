@@ -0,0 +1,128 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compareInts(a, b int) int {
+	if a < b {
+		return lessThan
+	} else if a == b {
+		return equal
+	}
+	return greaterThan
+}
+
+func TestGenericBtreeBasic(t *testing.T) {
+	require.Panics(t, func() {
+		newGenericBTree(1, compareInts)
+	})
+
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	nums := rand.Perm(N)
+	b := newGenericBTree(T, compareInts)
+	for _, num := range nums {
+		_, replaced := b.insert(num)
+		require.False(t, replaced, testInfo)
+	}
+	require.Equal(t, N, b.len, testInfo)
+
+	// reinsert N items
+	for _, num := range nums {
+		prev, replaced := b.insert(num)
+		require.True(t, replaced, testInfo)
+		require.Equal(t, num, prev, testInfo)
+	}
+	require.Equal(t, N, b.len, testInfo)
+
+	for _, num := range nums {
+		found, ok := b.search(num)
+		require.True(t, ok, testInfo)
+		require.Equal(t, num, found, testInfo)
+	}
+	for i := N; i < N+50; i++ {
+		_, ok := b.search(i)
+		require.False(t, ok, testInfo)
+	}
+}
+
+// compares allocations of the interface based btree against the generic one,
+// the generic version avoids boxing every int into an item
+func BenchmarkInsertInterface(b *testing.B) {
+	nums := rand.Perm(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewBTree(16)
+		for _, num := range nums {
+			tree.Insert(numItem(num))
+		}
+	}
+}
+
+func BenchmarkInsertGeneric(b *testing.B) {
+	nums := rand.Perm(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := newGenericBTree(16, compareInts)
+		for _, num := range nums {
+			tree.insert(num)
+		}
+	}
+}
+
+// searchKeys is the no. of keys in the trees BenchmarkSearchInterface and
+// BenchmarkSearchGeneric look up, enough to spill well out of the CPU caches.
+const searchKeys = 10000000
+
+var (
+	interfaceSearchTree *BTree
+	genericSearchTree   *genericBTree[int]
+)
+
+// compares lookups of random keys in the interface based btree, whose item
+// values live behind pointers, against the generic one holding them inline
+func BenchmarkSearchInterface(b *testing.B) {
+	if interfaceSearchTree == nil {
+		interfaceSearchTree = NewBTree(32)
+		for _, num := range rand.Perm(searchKeys) {
+			interfaceSearchTree.Insert(numItem(num))
+		}
+	}
+	probes := make([]Item, 1<<16)
+	for i := range probes {
+		probes[i] = numItem(rand.Intn(searchKeys))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		interfaceSearchTree.Search(probes[i%len(probes)])
+	}
+}
+
+func BenchmarkSearchGeneric(b *testing.B) {
+	if genericSearchTree == nil {
+		genericSearchTree = newGenericBTree(32, compareInts)
+		for _, num := range rand.Perm(searchKeys) {
+			genericSearchTree.insert(num)
+		}
+	}
+	probes := make([]int, 1<<16)
+	for i := range probes {
+		probes[i] = rand.Intn(searchKeys)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		genericSearchTree.search(probes[i%len(probes)])
+	}
+}
@@ -0,0 +1,93 @@
+package stdbtree
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// gobNode and gobBTree mirror node and BTree with exported fields so that the
+// tree's structure can be written out with encoding/gob.
+type gobNode struct {
+	Items    []Item
+	Children []*gobNode
+}
+
+type gobBTree struct {
+	T     int
+	Len   int
+	Multi bool
+	Root  *gobNode
+}
+
+func (n *node) toGob() *gobNode {
+	g := &gobNode{Items: n.items[:n.n]}
+	if !n.isLeaf {
+		g.Children = make([]*gobNode, n.n+1)
+		for i := range g.Children {
+			g.Children[i] = n.children[i].toGob()
+		}
+	}
+	return g
+}
+
+func (g *gobNode) toNode(t int) (*node, error) {
+	if g == nil {
+		return nil, fmt.Errorf("missing node")
+	}
+	isLeaf := len(g.Children) == 0
+	if len(g.Items) > 2*t-1 {
+		return nil, fmt.Errorf("node has %d items, at most %d allowed", len(g.Items), 2*t-1)
+	}
+	if !isLeaf && len(g.Children) != len(g.Items)+1 {
+		return nil, fmt.Errorf("node has %d items but %d children", len(g.Items), len(g.Children))
+	}
+	n := newNode(t, isLeaf)
+	n.n = copy(n.items, g.Items)
+	for i, gc := range g.Children {
+		c, err := gc.toNode(t)
+		if err != nil {
+			return nil, err
+		}
+		n.children[i] = c
+	}
+	n.size = n.computeSize()
+	return n, nil
+}
+
+// Encode writes the tree, including its node structure, to w using
+// encoding/gob. Since Item is an interface, the concrete types of the stored
+// items must be registered with gob.Register before calling Encode or
+// DecodeBTree.
+func (b *BTree) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(gobBTree{
+		T:     b.t,
+		Len:   b.len,
+		Multi: b.multi,
+		Root:  b.root.toGob(),
+	})
+}
+
+// DecodeBTree reads a tree written by Encode from r. The decoded tree has the
+// same minimum degree and node structure as the encoded one. A comparator set
+// with NewBTreeFunc is not encoded, the decoded tree orders its items by
+// their Compare method.
+func DecodeBTree(r io.Reader) (*BTree, error) {
+	var g gobBTree
+	if err := gob.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	b, err := NewBTreeChecked(g.T)
+	if err != nil {
+		return nil, err
+	}
+	root, err := g.Root.toNode(g.T)
+	if err != nil {
+		return nil, err
+	}
+	if root.size != g.Len {
+		return nil, fmt.Errorf("tree has %d items, expected %d", root.size, g.Len)
+	}
+	b.root, b.len, b.multi = root, g.Len, g.Multi
+	return b, nil
+}
@@ -0,0 +1,61 @@
+package stdbtree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gob.Register(numItem(0))
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	for _, size := range []int{0, 1, N} {
+		b := NewBTree(T)
+		for _, i := range rand.Perm(size) {
+			b.Insert(numItem(i))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, b.Encode(&buf), testInfo)
+		decoded, err := DecodeBTree(&buf)
+		require.NoError(t, err, testInfo)
+		require.NoError(t, checkInvariances(decoded, size), testInfo)
+		require.Equal(t, b.t, decoded.t, testInfo)
+		require.Equal(t, b.ToSlice(), decoded.ToSlice(), testInfo)
+	}
+
+	// garbage input is rejected
+	_, err := DecodeBTree(bytes.NewBufferString("not a btree"))
+	require.Error(t, err)
+}
+
+func ExampleBTree_Encode() {
+	// items are stored as interface values, so gob needs to know their
+	// concrete type, see the gob.Register call in init above.
+	b := NewBTree(2)
+	for i := 1; i <= 5; i++ {
+		b.Insert(numItem(i))
+	}
+	var buf bytes.Buffer
+	if err := b.Encode(&buf); err != nil {
+		panic(err)
+	}
+	decoded, err := DecodeBTree(&buf)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(decoded.ToSlice())
+	// Output: [1 2 3 4 5]
+}
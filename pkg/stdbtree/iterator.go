@@ -0,0 +1,242 @@
+package stdbtree
+
+// frame is a position within a node during iteration: the node and the index
+// of the next item in it to be visited. When iterating in reverse the next
+// item is the one at i-1 instead.
+type frame struct {
+	n *node
+	i int
+}
+
+// Iterator walks the items of a BTree in ascending, or for a reverse Iterator
+// descending, order. It keeps an explicit stack of frames from the root down
+// to the current node rather than recursing, so memory use is O(height) and
+// each step is O(1) amortized.
+//
+// The Iterator holds references into the tree's nodes, so any insert or delete
+// on the tree after the iterator is created invalidates it. Next panics if it
+// is called on an invalidated Iterator rather than skip or repeat items.
+type Iterator struct {
+	stack   []frame
+	curr    Item
+	reverse bool
+	tree    *BTree
+	mods    uint64 // tree.mods when the iterator was created
+	rank    int    // rank of curr, or of where the iterator is positioned
+}
+
+// Iterator returns an Iterator positioned before the smallest item in the tree.
+func (b *BTree) Iterator() *Iterator {
+	it := &Iterator{tree: b, mods: b.mods, rank: -1}
+	it.pushLeft(b.root)
+	return it
+}
+
+// ReverseIterator returns an Iterator that visits items in descending order,
+// positioned after the largest item in the tree.
+func (b *BTree) ReverseIterator() *Iterator {
+	it := &Iterator{reverse: true, tree: b, mods: b.mods, rank: b.len}
+	it.pushRight(b.root)
+	return it
+}
+
+// Seek returns an Iterator positioned so that the first call to Next yields
+// the smallest item in the tree greater than or equal to item.
+func (b *BTree) Seek(item Item) *Iterator {
+	it := &Iterator{tree: b, mods: b.mods, rank: -1}
+	n := b.root
+	for {
+		// items[:i] < item, so the walk resumes at children[i] and then
+		// items[i]
+		i, _ := n.find(b.cmp, item)
+		it.stack = append(it.stack, frame{n: n, i: i})
+		// the items skipped over count towards the rank, as in BTree.rank
+		it.rank += i
+		for j := 0; j < i; j++ {
+			it.rank += n.childSize(j)
+		}
+		if n.isLeaf {
+			return it
+		}
+		n = n.children[i]
+	}
+}
+
+// pushLeft pushes n and every node along its leftmost spine onto the stack.
+func (it *Iterator) pushLeft(n *node) {
+	for {
+		it.stack = append(it.stack, frame{n: n})
+		if n.isLeaf {
+			return
+		}
+		n = n.children[0]
+	}
+}
+
+// pushRight pushes n and every node along its rightmost spine onto the stack.
+func (it *Iterator) pushRight(n *node) {
+	for {
+		it.stack = append(it.stack, frame{n: n, i: n.n})
+		if n.isLeaf {
+			return
+		}
+		n = n.children[n.n]
+	}
+}
+
+// leftSpine returns the nodes from the root down to the leftmost leaf, the one
+// holding Min, in O(height). The nodes are the tree's own and must not be
+// modified.
+func (b *BTree) leftSpine() []*node {
+	spine := make([]*node, 0, b.Height())
+	for n := b.root; ; n = n.children[0] {
+		spine = append(spine, n)
+		if n.isLeaf {
+			return spine
+		}
+	}
+}
+
+// rightSpine returns the nodes from the root down to the rightmost leaf, the
+// one holding Max, in O(height). The nodes are the tree's own and must not be
+// modified.
+func (b *BTree) rightSpine() []*node {
+	spine := make([]*node, 0, b.Height())
+	for n := b.root; ; n = n.children[n.n] {
+		spine = append(spine, n)
+		if n.isLeaf {
+			return spine
+		}
+	}
+}
+
+// Next advances the iterator to the next item, returning false once all items
+// have been visited. It panics if the tree has been modified since the
+// iterator was created.
+func (it *Iterator) Next() bool {
+	if it.tree.mods != it.mods {
+		panic("btree modified during iteration")
+	}
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		n := top.n
+		switch {
+		case !it.reverse && top.i < n.n:
+			it.curr = n.items[top.i]
+			it.rank++
+			top.i++
+			if !n.isLeaf {
+				// items in the subtree to the right of curr come next
+				it.pushLeft(n.children[top.i])
+			}
+			return true
+		case it.reverse && top.i > 0:
+			top.i--
+			it.curr = n.items[top.i]
+			it.rank--
+			if !n.isLeaf {
+				// items in the subtree to the left of curr come next
+				it.pushRight(n.children[top.i])
+			}
+			return true
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+	it.curr = nil
+	return false
+}
+
+// Item returns the item the iterator is currently positioned at. It is nil
+// before the first call to Next and after Next returns false.
+func (it *Iterator) Item() Item {
+	return it.curr
+}
+
+// Rank returns the 0-based rank of the item the iterator is currently
+// positioned at, its index in ascending order among all the items in the
+// tree. It is kept up to date as the iterator moves, so it costs O(1) rather
+// than the O(height) of BTree.Rank. It is meaningful only while Item is not
+// nil.
+func (it *Iterator) Rank() int {
+	return it.rank
+}
+
+// First returns up to n of the smallest items in the tree in ascending order.
+// It stops walking as soon as it has collected them.
+func (b *BTree) First(n int) []Item {
+	return collect(b.Iterator(), n)
+}
+
+// Last returns up to n of the largest items in the tree in descending order.
+// It stops walking as soon as it has collected them.
+func (b *BTree) Last(n int) []Item {
+	return collect(b.ReverseIterator(), n)
+}
+
+// collect returns the next, at most n, items yielded by it.
+func collect(it *Iterator, n int) []Item {
+	if n <= 0 {
+		return []Item{}
+	}
+	items := make([]Item, 0, n)
+	for len(items) < n && it.Next() {
+		items = append(items, it.Item())
+	}
+	return items
+}
+
+// seekRank returns an Iterator positioned so that the first call to Next
+// yields the item of rank k, its index in ascending order. The iterator is
+// already exhausted if k >= Len. The subtree sizes let it skip whole subtrees
+// on the way down, as Select does, so it costs O(height).
+func (b *BTree) seekRank(k int) *Iterator {
+	if k < 0 {
+		k = 0
+	}
+	it := &Iterator{tree: b, mods: b.mods, rank: k - 1}
+	if k >= b.len {
+		return it
+	}
+	n := b.root
+	for {
+		var i int
+		for i = 0; i < n.n; i++ {
+			cs := n.childSize(i)
+			if k < cs {
+				break
+			}
+			k -= cs
+			if k == 0 {
+				// items[i] comes next, children[i] is skipped entirely
+				it.stack = append(it.stack, frame{n: n, i: i})
+				return it
+			}
+			k--
+		}
+		// the item of rank k falls within children[i], after which the walk
+		// resumes at items[i]
+		it.stack = append(it.stack, frame{n: n, i: i})
+		n = n.children[i]
+	}
+}
+
+// Page returns up to limit items in ascending order, starting at the item at
+// position offset, counting from 0, for paging through the tree. It uses the
+// subtree sizes to reach offset in O(height), taking O(height + limit)
+// overall. It returns an empty slice if offset is at or beyond Len or limit is
+// not positive.
+func (b *BTree) Page(offset, limit int) []Item {
+	return collect(b.seekRank(offset), limit)
+}
+
+// pageLinear is Page done by walking past the first offset items one at a
+// time, in O(offset + limit). It serves as a reference for Page.
+func (b *BTree) pageLinear(offset, limit int) []Item {
+	it := b.Iterator()
+	for i := 0; i < offset; i++ {
+		if !it.Next() {
+			break
+		}
+	}
+	return collect(it, limit)
+}
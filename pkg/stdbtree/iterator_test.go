@@ -0,0 +1,327 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// empty tree yields nothing
+	b := NewBTree(T)
+	it := b.Iterator()
+	require.Nil(t, it.Item(), testInfo)
+	require.False(t, it.Next(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	// items are visited exactly once in ascending order
+	it = b.Iterator()
+	var count int
+	for it.Next() {
+		require.Equal(t, numItem(count), it.Item(), testInfo)
+		count++
+	}
+	require.Equal(t, N, count, testInfo)
+	require.Nil(t, it.Item(), testInfo)
+	require.False(t, it.Next(), testInfo)
+}
+
+func TestReverseIterator(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.False(t, b.ReverseIterator().Next(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	for i := 0; i < N; i += 4 {
+		b.Delete(numItem(i))
+	}
+
+	// the reverse sequence equals ToSlice reversed
+	items := b.ToSlice()
+	it := b.ReverseIterator()
+	for i := len(items) - 1; i >= 0; i-- {
+		require.True(t, it.Next(), testInfo)
+		require.Equal(t, items[i], it.Item(), testInfo)
+	}
+	require.False(t, it.Next(), testInfo)
+	require.Nil(t, it.Item(), testInfo)
+}
+
+func TestSeek(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.False(t, b.Seek(numItem(0)).Next(), testInfo)
+
+	// store multiples of 3 so probes land both on and between items
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(3 * i))
+	}
+	for probe := -2; probe < 3*N+2; probe++ {
+		first := probe
+		if first < 0 {
+			first = 0
+		}
+		for first%3 != 0 {
+			first++
+		}
+		// walking from the seek position yields every item from there on
+		it := b.Seek(numItem(probe))
+		for want := first; want < 3*N; want += 3 {
+			require.True(t, it.Next(), testInfo, probe)
+			require.Equal(t, numItem(want), it.Item(), testInfo, probe)
+		}
+		require.False(t, it.Next(), testInfo, probe)
+	}
+}
+
+func TestIteratorRank(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	for _, i := range rand.Perm(N)[:N/3] {
+		b.Delete(numItem(2 * i))
+	}
+
+	// every item's rank matches Select, going either way
+	var visited int
+	for it := b.Iterator(); it.Next(); visited++ {
+		require.Equal(t, visited, it.Rank(), testInfo)
+		require.Equal(t, it.Item(), b.Select(it.Rank()), testInfo)
+	}
+	require.Equal(t, b.Len(), visited, testInfo)
+	visited = 0
+	for it := b.ReverseIterator(); it.Next(); visited++ {
+		require.Equal(t, b.Len()-1-visited, it.Rank(), testInfo)
+		require.Equal(t, it.Item(), b.Select(it.Rank()), testInfo)
+	}
+
+	// and after seeking to a present or absent item
+	for i := 0; i < 50; i++ {
+		probe := numItem(rand.Intn(2*N+2) - 1)
+		it := b.Seek(probe)
+		for j := 0; j < 5 && it.Next(); j++ {
+			require.Equal(t, b.Rank(probe)+j, it.Rank(), testInfo, probe)
+			require.Equal(t, it.Item(), b.Select(it.Rank()), testInfo, probe)
+		}
+	}
+
+	// equal items in a multiset each get their own rank
+	m := NewMultiBTree(T)
+	for i := 0; i < N; i++ {
+		m.Insert(numItem(i % 10))
+	}
+	it := m.Seek(numItem(5))
+	for j := 0; j < N/10 && it.Next(); j++ {
+		require.Equal(t, (N/10)*5+j, it.Rank(), testInfo)
+		require.Equal(t, numItem(5), it.Item(), testInfo)
+	}
+}
+
+func TestSpines(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, []*node{b.root}, b.leftSpine(), testInfo)
+	require.Equal(t, []*node{b.root}, b.rightSpine(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+		left, right := b.leftSpine(), b.rightSpine()
+		require.Len(t, left, b.Height(), testInfo)
+		require.Len(t, right, b.Height(), testInfo)
+		for j := 1; j < len(left); j++ {
+			require.Same(t, left[j-1].children[0], left[j], testInfo)
+			require.Same(t, right[j-1].children[right[j-1].n], right[j], testInfo)
+		}
+		l, r := left[len(left)-1], right[len(right)-1]
+		require.True(t, l.isLeaf && r.isLeaf, testInfo)
+		require.Equal(t, b.Min(), l.items[0], testInfo)
+		require.Equal(t, b.Max(), r.items[r.n-1], testInfo)
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.First(5), testInfo)
+	require.Empty(t, b.Last(5), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	items := b.ToSlice()
+	for _, n := range []int{-1, 0, 1, 10, N - 1, N, N + 10} {
+		want := n
+		if want < 0 {
+			want = 0
+		} else if want > N {
+			want = N
+		}
+		first, last := b.First(n), b.Last(n)
+		require.Equal(t, items[:want], first, testInfo, n)
+		require.Len(t, last, want, testInfo, n)
+		for i, item := range last {
+			require.Equal(t, items[N-1-i], item, testInfo, n)
+		}
+	}
+}
+
+func TestIteratorInvalidation(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	newTree := func() *BTree {
+		b := NewBTree(T)
+		for _, i := range rand.Perm(N) {
+			b.Insert(numItem(i))
+		}
+		return b
+	}
+	mutations := map[string]func(b *BTree){
+		"insert":         func(b *BTree) { b.Insert(numItem(N)) },
+		"replace insert": func(b *BTree) { b.Insert(numItem(0)) },
+		"delete":         func(b *BTree) { b.Delete(numItem(0)) },
+		"delete min":     func(b *BTree) { b.DeleteMin() },
+		"replace":        func(b *BTree) { b.Replace(numItem(0)) },
+		"clear":          func(b *BTree) { b.Clear() },
+		"compact":        func(b *BTree) { b.Compact() },
+	}
+	iterators := map[string]func(b *BTree) *Iterator{
+		"forward": (*BTree).Iterator,
+		"reverse": (*BTree).ReverseIterator,
+		"seek":    func(b *BTree) *Iterator { return b.Seek(numItem(N / 2)) },
+	}
+	for name, mutate := range mutations {
+		for itName, newIterator := range iterators {
+			b := newTree()
+			it := newIterator(b)
+			require.True(t, it.Next(), testInfo, name, itName)
+			mutate(b)
+			require.PanicsWithValue(t, "btree modified during iteration", func() { it.Next() }, testInfo, name, itName)
+		}
+	}
+
+	// reads leave iterators valid
+	b := newTree()
+	it := b.Iterator()
+	require.True(t, it.Next(), testInfo)
+	b.Search(numItem(1))
+	b.RangeScan(nil, nil)
+	b.Rank(numItem(5))
+	// as does a Replace that finds nothing, leaving the tree unchanged
+	b.Replace(numItem(N))
+	var count int
+	for ok := true; ok; ok = it.Next() {
+		count++
+	}
+	require.Equal(t, N, count, testInfo)
+}
+
+func TestPage(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.Page(0, 10), testInfo)
+	require.Empty(t, b.pageLinear(0, 10), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	items := b.ToSlice()
+	for _, offset := range []int{-1, 0, 1, 2*T - 1, 2 * T, N / 2, N - 1, N, N + 10} {
+		for _, limit := range []int{-1, 0, 1, 7, N - 1, N, N + 10} {
+			lo, hi := offset, offset+limit
+			if lo < 0 {
+				lo, hi = 0, limit
+			}
+			if lo > N {
+				lo = N
+			}
+			if hi > N {
+				hi = N
+			}
+			want := []Item{}
+			if hi > lo {
+				want = items[lo:hi]
+			}
+			info := fmt.Sprintf("%s offset = %d, limit = %d", testInfo, offset, limit)
+			require.Equal(t, want, b.Page(offset, limit), info)
+			require.Equal(t, want, b.pageLinear(offset, limit), info)
+		}
+	}
+
+	// every offset, with the ranks of the iterator it is read through
+	for offset := 0; offset < N; offset++ {
+		it := b.seekRank(offset)
+		require.True(t, it.Next(), testInfo)
+		require.Equal(t, items[offset], it.Item(), testInfo, offset)
+		require.Equal(t, offset, it.Rank(), testInfo, offset)
+	}
+}
+
+func BenchmarkPage(b *testing.B) {
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := benchTree(t, benchItems())
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tree.Page(benchSize-20, 10)
+		}
+	})
+}
+
+func BenchmarkPageLinear(b *testing.B) {
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := benchTree(t, benchItems())
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tree.pageLinear(benchSize-20, 10)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package stdbtree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MarshalJSON implements json.Marshaler, encoding the tree as a JSON array of
+// its items in ascending order. Unlike Encode the node structure is not kept,
+// which makes the output easy to consume from other languages.
+func (b *BTree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.ToSlice())
+}
+
+// SetJSONDecoder sets the hook UnmarshalJSON uses to turn each element of the
+// array back into an Item. It is needed because the concrete type of the
+// items cannot be recovered from the JSON alone.
+func (b *BTree) SetJSONDecoder(decode func(data []byte) (Item, error)) {
+	b.decodeJSON = decode
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the
+// tree with the items of a JSON array as written by MarshalJSON. Every
+// element is decoded with the hook set by SetJSONDecoder and the tree is then
+// bulk loaded, keeping its minimum degree. The elements need not be sorted;
+// as with InsertMany the last of several equal items wins unless the tree is
+// a multiset. The tree is left unchanged if an error is returned.
+func (b *BTree) UnmarshalJSON(data []byte) error {
+	if b.decodeJSON == nil {
+		return errors.New("btree has no JSON decoder for its items, see SetJSONDecoder")
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	items := make([]Item, len(raw))
+	for i, r := range raw {
+		item, err := b.decodeJSON(r)
+		if err != nil {
+			return fmt.Errorf("decoding item %d: %w", i, err)
+		}
+		if item == nil {
+			return fmt.Errorf("decoding item %d: decoder returned a nil item", i)
+		}
+		items[i] = item
+	}
+	b.Clear()
+	b.InsertMany(items)
+	return nil
+}
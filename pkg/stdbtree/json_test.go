@@ -0,0 +1,64 @@
+package stdbtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeNumItem(data []byte) (Item, error) {
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return numItem(n), nil
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	for _, size := range []int{0, 1, N} {
+		b := NewBTree(T)
+		for _, i := range rand.Perm(size) {
+			b.Insert(numItem(i))
+		}
+		data, err := json.Marshal(b)
+		require.NoError(t, err, testInfo)
+
+		decoded := NewBTree(T)
+		decoded.Insert(numItem(-1)) // replaced by the decoded items
+		decoded.SetJSONDecoder(decodeNumItem)
+		require.NoError(t, json.Unmarshal(data, decoded), testInfo)
+		require.NoError(t, checkInvariances(decoded, size), testInfo)
+		require.Equal(t, b.ToSlice(), decoded.ToSlice(), testInfo)
+	}
+
+	b := NewBTree(2)
+	for i := 3; i >= 1; i-- {
+		b.Insert(numItem(i))
+	}
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	require.Equal(t, "[1,2,3]", string(data))
+
+	// unsorted input is sorted, in a set the last of equal items wins
+	decoded := NewBTree(2)
+	decoded.SetJSONDecoder(decodeNumItem)
+	require.NoError(t, json.Unmarshal([]byte("[3,1,2,1]"), decoded))
+	require.NoError(t, checkInvariances(decoded, 3))
+	require.Equal(t, []Item{numItem(1), numItem(2), numItem(3)}, decoded.ToSlice())
+
+	// errors leave the tree unchanged
+	require.Error(t, json.Unmarshal([]byte("[1,\"a\"]"), decoded))
+	require.Error(t, json.Unmarshal([]byte("{}"), decoded))
+	require.NoError(t, checkInvariances(decoded, 3))
+	require.Error(t, json.Unmarshal(data, NewBTree(2)), "decoder is required")
+}
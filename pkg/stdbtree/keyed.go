@@ -0,0 +1,39 @@
+package stdbtree
+
+import "encoding/binary"
+
+// KeyedItem is an Item that carries a cheap sort key alongside whatever
+// Compare looks at, typically computed once when the item is created. The key
+// must agree with Compare: a.SortKey() < b.SortKey() must imply that a is less
+// than b. Items whose keys are equal are told apart by Compare.
+type KeyedItem interface {
+	Item
+	SortKey() uint64
+}
+
+// NewKeyedBTree creates an empty BTree with minimum degree t holding
+// KeyedItems, for items whose Compare is expensive, e.g. long strings or
+// composite keys. Searches compare the sort keys first and only call Compare
+// on a tie, so the more the keys tell items apart the fewer full comparisons
+// are made. Every item inserted or looked up must implement KeyedItem.
+func NewKeyedBTree(t int) *BTree {
+	return NewBTreeFunc(t, compareKeyed)
+}
+
+func compareKeyed(a, b Item) int {
+	ka, kb := a.(KeyedItem).SortKey(), b.(KeyedItem).SortKey()
+	if ka < kb {
+		return lessThan
+	} else if ka > kb {
+		return greaterThan
+	}
+	return a.Compare(b)
+}
+
+// StringSortKey returns a sort key for s agreeing with the byte-wise order of
+// strings: its first 8 bytes read as a big-endian integer, padded with zeros.
+func StringSortKey(s string) uint64 {
+	var buf [8]byte
+	copy(buf[:], s)
+	return binary.BigEndian.Uint64(buf[:])
+}
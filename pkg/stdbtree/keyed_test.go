@@ -0,0 +1,116 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keyedStr is a string item caching its StringSortKey.
+type keyedStr struct {
+	s   string
+	key uint64
+}
+
+func newKeyedStr(s string) *keyedStr {
+	return &keyedStr{s: s, key: StringSortKey(s)}
+}
+
+func (k *keyedStr) Compare(other Item) int {
+	return strings.Compare(k.s, other.(*keyedStr).s)
+}
+
+func (k *keyedStr) SortKey() uint64 {
+	return k.key
+}
+
+// randString returns a random string of length n over a small alphabet, so
+// that shorter ones share prefixes.
+func randString(n int) string {
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = "abc"[rand.Intn(3)]
+	}
+	return string(s)
+}
+
+func TestStringSortKey(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	testInfo := fmt.Sprintf("[seedVal = %d]", seedVal) // for replication
+
+	for _, s := range []string{"", "a", "ab", "abcdefgh", "abcdefghi"} {
+		require.LessOrEqual(t, StringSortKey(s), StringSortKey(s+"\x00"), s)
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := randString(rand.Intn(12)), randString(rand.Intn(12))
+		if StringSortKey(a) < StringSortKey(b) {
+			require.Less(t, a, b, testInfo)
+		}
+	}
+}
+
+func TestKeyedBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// lengths either side of 8 so that keys both settle and tie
+	b := NewKeyedBTree(T)
+	present := make(map[string]bool)
+	for i := 0; i < 3*N; i++ {
+		s := randString(rand.Intn(12) + 1)
+		if rand.Intn(3) > 0 {
+			b.Insert(newKeyedStr(s))
+			present[s] = true
+		} else {
+			require.Equal(t, present[s], b.Delete(newKeyedStr(s)) != nil, testInfo, s)
+			delete(present, s)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+	var expected []string
+	for s := range present {
+		expected = append(expected, s)
+		require.Equal(t, s, b.Search(newKeyedStr(s)).(*keyedStr).s, testInfo)
+	}
+	sort.Strings(expected)
+	for i, item := range b.ToSlice() {
+		require.Equal(t, expected[i], item.(*keyedStr).s, testInfo)
+	}
+}
+
+// BenchmarkSearchLongStrings and BenchmarkSearchLongStringsKeyed compare
+// lookups of long string keys with and without a cached sort key.
+func benchmarkSearchLongStrings(b *testing.B, tree *BTree, newItem func(s string) Item) {
+	var probes []Item
+	for i := 0; i < 100000; i++ {
+		s := fmt.Sprintf("%016x%s", rand.Uint64(), strings.Repeat("x", 240))
+		item := newItem(s)
+		tree.Insert(item)
+		probes = append(probes, newItem(s))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(probes[i%len(probes)])
+	}
+}
+
+func BenchmarkSearchLongStrings(b *testing.B) {
+	benchmarkSearchLongStrings(b, NewBTree(32), func(s string) Item {
+		return strItem(s)
+	})
+}
+
+func BenchmarkSearchLongStringsKeyed(b *testing.B) {
+	benchmarkSearchLongStrings(b, NewKeyedBTree(32), func(s string) Item {
+		return newKeyedStr(s)
+	})
+}
@@ -0,0 +1,296 @@
+package stdbtree
+
+// mapNode is a node of a Map. It stores keys and their values in parallel
+// slices, values[i] belonging to keys[i], so that only keys are ever compared.
+type mapNode[K, V any] struct {
+	isLeaf   bool
+	n        int // tracks no. of keys in a node
+	keys     []K
+	values   []V
+	children []*mapNode[K, V]
+}
+
+func newMapNode[K, V any](t int, isLeaf bool) *mapNode[K, V] {
+	var children []*mapNode[K, V] = nil
+	if !isLeaf { // if is internal
+		children = make([]*mapNode[K, V], 2*t)
+	}
+	return &mapNode[K, V]{
+		isLeaf:   isLeaf,
+		keys:     make([]K, 2*t-1),
+		values:   make([]V, 2*t-1),
+		children: children,
+	}
+}
+
+// find returns the index of the first key in n that is not less than key, and
+// whether the key at that index is equal to key.
+func (n *mapNode[K, V]) find(cmp func(a, b K) int, key K) (i int, found bool) {
+loop:
+	for i = 0; i < n.n; i++ {
+		switch cmp(key, n.keys[i]) {
+		case equal:
+			found = true
+			break loop
+		case lessThan:
+			break loop
+		}
+	}
+	return
+}
+
+func (n *mapNode[K, V]) get(cmp func(a, b K) int, key K) (value V, ok bool) {
+	for {
+		i, found := n.find(cmp, key)
+		if found {
+			return n.values[i], true
+		}
+		if n.isLeaf {
+			return
+		}
+		n = n.children[i]
+	}
+}
+
+// insertAt inserts key and value at index i of n, shifting the entries after
+// it right. It does not touch children.
+func (n *mapNode[K, V]) insertAt(i int, key K, value V) {
+	copy(n.keys[i+1:], n.keys[i:n.n])
+	copy(n.values[i+1:], n.values[i:n.n])
+	n.keys[i], n.values[i] = key, value
+	n.n++
+}
+
+// removeAt removes the entry at index i of n, shifting the entries after it
+// left. It does not touch children.
+func (n *mapNode[K, V]) removeAt(i int) (key K, value V) {
+	key, value = n.keys[i], n.values[i]
+	copy(n.keys[i:], n.keys[i+1:n.n])
+	copy(n.values[i:], n.values[i+1:n.n])
+	n.n--
+	var zeroK K
+	var zeroV V
+	n.keys[n.n], n.values[n.n] = zeroK, zeroV
+	return
+}
+
+func (n *mapNode[K, V]) put(t int, cmp func(a, b K) int, key K, value V) (old V, existed bool) {
+	for {
+		i, found := n.find(cmp, key)
+		if found {
+			old, n.values[i] = n.values[i], value
+			return old, true
+		}
+		if n.isLeaf {
+			n.insertAt(i, key, value)
+			return
+		}
+		if n.children[i].n == 2*t-1 {
+			n.splitChild(t, i)
+			switch cmp(key, n.keys[i]) {
+			case equal:
+				old, n.values[i] = n.values[i], value
+				return old, true
+			case greaterThan:
+				// go to newly upped right child
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+func (n *mapNode[K, V]) splitChild(t int, i int) {
+	// let y be the ith child of node n.
+	y := n.children[i]
+
+	// halve y and move the upper half to new node z, values go along with
+	// their keys
+	z := newMapNode[K, V](t, y.isLeaf)
+	copy(z.keys, y.keys[t:])
+	copy(z.values, y.values[t:])
+	z.n = t - 1
+	if !y.isLeaf { // only internal nodes have children
+		copy(z.children, y.children[t:])
+	}
+
+	// move median entry up to parent (node n)
+	n.insertAt(i, y.keys[t-1], y.values[t-1])
+	y.n = t - 1
+
+	// add z as node n's child
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = z
+}
+
+// remove deletes key from the subtree rooted at n following CLRS
+// B-Tree-Delete, see node.remove. typ selects whether key, the min or the max
+// is removed.
+func (n *mapNode[K, V]) remove(t int, cmp func(a, b K) int, key K, typ toRemove) (rkey K, rvalue V, ok bool) {
+	var i int
+	var found bool
+	switch typ {
+	case removeMax:
+		if n.isLeaf {
+			rkey, rvalue = n.removeAt(n.n - 1)
+			return rkey, rvalue, true
+		}
+		i = n.n
+	case removeMin:
+		if n.isLeaf {
+			rkey, rvalue = n.removeAt(0)
+			return rkey, rvalue, true
+		}
+		i = 0
+	case removeItem:
+		i, found = n.find(cmp, key)
+		if n.isLeaf {
+			// case 1: key is in a leaf, or absent altogether
+			if found {
+				rkey, rvalue = n.removeAt(i)
+				return rkey, rvalue, true
+			}
+			return
+		}
+	}
+
+	if found {
+		// case 2: key is in internal node n
+		y, z := n.children[i], n.children[i+1]
+		rkey, rvalue, ok = n.keys[i], n.values[i], true
+		switch {
+		case y.n >= t:
+			// 2a: replace entry with its predecessor
+			n.keys[i], n.values[i], _ = y.remove(t, cmp, key, removeMax)
+		case z.n >= t:
+			// 2b: replace entry with its successor
+			n.keys[i], n.values[i], _ = z.remove(t, cmp, key, removeMin)
+		default:
+			// 2c: merge entry and z into y, then delete key from y
+			n.mergeChildren(i)
+			return y.remove(t, cmp, key, removeItem)
+		}
+		return
+	}
+
+	// case 3: key, if present, is in the subtree rooted at children[i]
+	if n.children[i].n == t-1 {
+		i = n.fillChild(t, i)
+	}
+	return n.children[i].remove(t, cmp, key, typ)
+}
+
+// fillChild ensures that the ith child of n has at least t keys, see
+// node.fillChild.
+func (n *mapNode[K, V]) fillChild(t int, i int) int {
+	switch {
+	case i > 0 && n.children[i-1].n >= t:
+		// borrow from left sibling through the separator
+		c, left := n.children[i], n.children[i-1]
+		c.insertAt(0, n.keys[i-1], n.values[i-1])
+		if !c.isLeaf {
+			copy(c.children[1:], c.children[:c.n])
+			c.children[0] = left.children[left.n]
+			left.children[left.n] = nil
+		}
+		n.keys[i-1], n.values[i-1] = left.removeAt(left.n - 1)
+	case i < n.n && n.children[i+1].n >= t:
+		// borrow from right sibling through the separator
+		c, right := n.children[i], n.children[i+1]
+		c.insertAt(c.n, n.keys[i], n.values[i])
+		if !c.isLeaf {
+			c.children[c.n] = right.children[0]
+			copy(right.children, right.children[1:right.n+1])
+			right.children[right.n] = nil
+		}
+		n.keys[i], n.values[i] = right.removeAt(0)
+	default:
+		if i == n.n { // rightmost child has no right sibling, merge with left
+			i--
+		}
+		n.mergeChildren(i)
+	}
+	return i
+}
+
+// mergeChildren merges the separator entry i and the (i+1)th child into the
+// ith child, see node.mergeChildren.
+func (n *mapNode[K, V]) mergeChildren(i int) {
+	y, z := n.children[i], n.children[i+1]
+
+	y.insertAt(y.n, n.keys[i], n.values[i])
+	copy(y.keys[y.n:], z.keys[:z.n])
+	copy(y.values[y.n:], z.values[:z.n])
+	if !y.isLeaf {
+		copy(y.children[y.n:], z.children[:z.n+1])
+	}
+	y.n += z.n
+
+	// remove separator and z from n
+	n.removeAt(i)
+	copy(n.children[i+1:], n.children[i+2:n.n+2])
+	n.children[n.n+1] = nil
+}
+
+// Map is an ordered map from keys of type K to values of type V backed by a
+// B-tree. Values are kept out of the comparison path: cmp only ever sees
+// keys, and must return -1, 0 or 1 according to how a compares to b.
+type Map[K, V any] struct {
+	root *mapNode[K, V]
+	cmp  func(a, b K) int
+	t    int
+	len  int
+}
+
+// NewMap creates an empty Map with minimum degree t ordered by cmp, see
+// NewBTree for the constraints on t.
+func NewMap[K, V any](t int, cmp func(a, b K) int) *Map[K, V] {
+	if err := checkDegree(t); err != nil {
+		panic(err)
+	}
+	return &Map[K, V]{
+		root: newMapNode[K, V](t, true),
+		cmp:  cmp,
+		t:    t,
+	}
+}
+
+// Put associates value with key. If key was already present its previous
+// value is returned with existed set.
+func (m *Map[K, V]) Put(key K, value V) (old V, existed bool) {
+	if m.root.n == (2*m.t - 1) {
+		oldRoot := m.root
+		m.root = newMapNode[K, V](m.t, false)
+		m.root.children[0] = oldRoot
+		m.root.splitChild(m.t, 0)
+	}
+	old, existed = m.root.put(m.t, m.cmp, key, value)
+	if !existed {
+		m.len++
+	}
+	return
+}
+
+// Get returns the value associated with key, ok is unset if key is absent.
+func (m *Map[K, V]) Get(key K) (value V, ok bool) {
+	return m.root.get(m.cmp, key)
+}
+
+// Delete removes key from the map, returning its value. ok is unset if key was
+// absent.
+func (m *Map[K, V]) Delete(key K) (value V, ok bool) {
+	_, value, ok = m.root.remove(m.t, m.cmp, key, removeItem)
+	if m.root.n == 0 && !m.root.isLeaf {
+		// root emptied out after a merge, shrink the tree height
+		m.root = m.root.children[0]
+	}
+	if ok {
+		m.len--
+	}
+	return
+}
+
+// Len returns the number of keys in the map.
+func (m *Map[K, V]) Len() int {
+	return m.len
+}
@@ -0,0 +1,89 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// for debugging/testing
+func checkMapInvariances[K, V any](m *Map[K, V], expectedLen int) error {
+	var keys []K
+	var traverse func(n *mapNode[K, V], depth int) error
+	leafDepth := -1
+	traverse = func(n *mapNode[K, V], depth int) error {
+		if n != m.root && (n.n < m.t-1 || n.n > 2*m.t-1) {
+			return fmt.Errorf("One of the nodes has invalid n: %d", n.n)
+		}
+		for i := 0; i < n.n; i++ {
+			if !n.isLeaf {
+				if err := traverse(n.children[i], depth+1); err != nil {
+					return err
+				}
+			}
+			keys = append(keys, n.keys[i])
+		}
+		if !n.isLeaf {
+			return traverse(n.children[n.n], depth+1)
+		}
+		if leafDepth == -1 {
+			leafDepth = depth
+		} else if leafDepth != depth {
+			return fmt.Errorf("one of the leaf nodes does not have the same height as the rest: %d vs %d", depth, leafDepth)
+		}
+		return nil
+	}
+	if err := traverse(m.root, 1); err != nil {
+		return err
+	}
+	if len(keys) != expectedLen || m.len != expectedLen {
+		return fmt.Errorf("Expected map to have len %d, instead has len %d (%d keys)", expectedLen, m.len, len(keys))
+	}
+	for i := 1; i < len(keys); i++ {
+		if m.cmp(keys[i-1], keys[i]) != lessThan {
+			return fmt.Errorf("map keys not in sorted order (ascending)\n: %v comes before %v", keys[i-1], keys[i])
+		}
+	}
+	return nil
+}
+
+func TestMap(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 200
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	m := NewMap[int, string](T, compareInts)
+	expected := make(map[int]string)
+	for i := 0; i < 20*N; i++ {
+		key := rand.Intn(N)
+		if rand.Intn(3) > 0 {
+			value := fmt.Sprint(i)
+			old, existed := m.Put(key, value)
+			prev, ok := expected[key]
+			require.Equal(t, ok, existed, testInfo)
+			require.Equal(t, prev, old, testInfo)
+			expected[key] = value
+		} else {
+			value, ok := m.Delete(key)
+			prev, existed := expected[key]
+			require.Equal(t, existed, ok, testInfo)
+			require.Equal(t, prev, value, testInfo)
+			delete(expected, key)
+		}
+		require.NoError(t, checkMapInvariances(m, len(expected)), testInfo)
+	}
+
+	// values stay attached to their keys through the splits and merges
+	for key := -1; key <= N; key++ {
+		value, ok := m.Get(key)
+		prev, existed := expected[key]
+		require.Equal(t, existed, ok, testInfo)
+		require.Equal(t, prev, value, testInfo)
+	}
+	require.Equal(t, len(expected), m.Len(), testInfo)
+}
@@ -0,0 +1,122 @@
+package stdbtree
+
+// ordered is the set of types whose values can be compared with < and ==.
+// Floating point types are left out since NaN does not compare consistently.
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~string
+}
+
+// integer is the subset of ordered made up of the integer types.
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+func compareOrdered[T ordered](a, b T) int {
+	if a < b {
+		return lessThan
+	} else if a == b {
+		return equal
+	}
+	return greaterThan
+}
+
+// OrderedBTree is a btree of values with a natural order, such as ints or
+// strings, stored inline and compared directly, so there is no Item type to
+// write. See IntBTree and StringBTree. The zero value is not usable, use
+// NewOrderedBTree to create one.
+type OrderedBTree[T ordered] struct {
+	tree *genericBTree[T]
+}
+
+// IntBTree is an OrderedBTree of ints.
+type IntBTree = OrderedBTree[int]
+
+// StringBTree is an OrderedBTree of strings.
+type StringBTree = OrderedBTree[string]
+
+// NewOrderedBTree creates an empty OrderedBTree with minimum degree t, see
+// NewBTree for the constraints on t. It panics if t is invalid.
+func NewOrderedBTree[T ordered](t int) *OrderedBTree[T] {
+	return &OrderedBTree[T]{tree: newGenericBTree(t, compareOrdered[T])}
+}
+
+// NewIntBTree creates an empty IntBTree with minimum degree t.
+func NewIntBTree(t int) *IntBTree {
+	return NewOrderedBTree[int](t)
+}
+
+// NewStringBTree creates an empty StringBTree with minimum degree t.
+func NewStringBTree(t int) *StringBTree {
+	return NewOrderedBTree[string](t)
+}
+
+// Insert adds x to the tree. If x is already present it is replaced and
+// returned with replaced set.
+func (b *OrderedBTree[T]) Insert(x T) (prev T, replaced bool) {
+	return b.tree.insert(x)
+}
+
+// Search returns x and whether it is present in the tree.
+func (b *OrderedBTree[T]) Search(x T) (found T, ok bool) {
+	return b.tree.search(x)
+}
+
+// Delete removes x from the tree and returns it with ok set, or ok unset if x
+// is not present.
+func (b *OrderedBTree[T]) Delete(x T) (removed T, ok bool) {
+	return b.tree.delete(x)
+}
+
+// Len returns the number of values in the tree.
+func (b *OrderedBTree[T]) Len() int {
+	return b.tree.len
+}
+
+// ForEach calls fn for every value in the tree in ascending order, stopping as
+// soon as fn returns false.
+func (b *OrderedBTree[T]) ForEach(fn func(x T) bool) {
+	b.tree.root.ascend(fn)
+}
+
+// ToSlice returns every value in the tree in ascending order.
+func (b *OrderedBTree[T]) ToSlice() []T {
+	xs := make([]T, 0, b.tree.len)
+	b.ForEach(func(x T) bool {
+		xs = append(xs, x)
+		return true
+	})
+	return xs
+}
+
+// MissingInRange returns, in ascending order, every integer x with
+// lo <= x <= hi that is not in b, e.g. to find the holes in a sequence of IDs.
+// It walks the values in the range once, emitting the gaps between
+// consecutive ones, and so takes time proportional to the size of the range.
+func MissingInRange[T integer](b *OrderedBTree[T], lo, hi T) []T {
+	var missing []T
+	if lo > hi {
+		return missing
+	}
+	// next is the smallest value not yet accounted for, done whether the
+	// walk got past hi, which it can without next overflowing
+	next, done := lo, false
+	b.tree.ascendRange(lo, hi, func(x T) bool {
+		for ; next < x; next++ {
+			missing = append(missing, next)
+		}
+		if x == hi {
+			done = true
+			return false
+		}
+		next = x + 1
+		return true
+	})
+	for ; !done; next++ {
+		missing = append(missing, next)
+		done = next == hi
+	}
+	return missing
+}
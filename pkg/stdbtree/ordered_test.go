@@ -0,0 +1,116 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntBTree(t *testing.T) {
+	require.Panics(t, func() {
+		NewIntBTree(1)
+	})
+
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewIntBTree(T)
+	present := make(map[int]bool)
+	for i := 0; i < 10*N; i++ {
+		x := rand.Intn(N)
+		if rand.Intn(3) > 0 {
+			prev, replaced := b.Insert(x)
+			require.Equal(t, present[x], replaced, testInfo)
+			if replaced {
+				require.Equal(t, x, prev, testInfo)
+			}
+			present[x] = true
+		} else {
+			removed, ok := b.Delete(x)
+			require.Equal(t, present[x], ok, testInfo)
+			if ok {
+				require.Equal(t, x, removed, testInfo)
+			}
+			delete(present, x)
+		}
+		found, ok := b.Search(x)
+		require.Equal(t, present[x], ok, testInfo)
+		if ok {
+			require.Equal(t, x, found, testInfo)
+		}
+		require.NoError(t, checkGenericInvariances(b.tree, len(present)), testInfo)
+	}
+	require.Equal(t, len(present), b.Len(), testInfo)
+
+	var expected []int
+	for x := range present {
+		expected = append(expected, x)
+	}
+	sort.Ints(expected)
+	require.Equal(t, expected, b.ToSlice(), testInfo)
+
+	// ForEach stops early
+	var visited []int
+	b.ForEach(func(x int) bool {
+		visited = append(visited, x)
+		return len(visited) < 5
+	})
+	require.Equal(t, expected[:5], visited, testInfo)
+}
+
+func TestMissingInRange(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewIntBTree(T)
+	require.Equal(t, []int{3, 4, 5}, MissingInRange(b, 3, 5), testInfo)
+	present := make(map[int]bool)
+	for i := 0; i < N; i++ {
+		if rand.Intn(3) > 0 {
+			b.Insert(i)
+			present[i] = true
+		}
+	}
+	for i := 0; i < 200; i++ {
+		lo, hi := rand.Intn(N+20)-10, rand.Intn(N+20)-10
+		var expected []int
+		for x := lo; x <= hi; x++ {
+			if !present[x] {
+				expected = append(expected, x)
+			}
+		}
+		require.Equal(t, expected, MissingInRange(b, lo, hi), "%s [lo = %d, hi = %d]", testInfo, lo, hi)
+	}
+
+	// bounds at the edges of the type don't overflow
+	u := NewOrderedBTree[uint8](T)
+	for _, x := range []uint8{0, 1, 3, 254, 255} {
+		u.Insert(x)
+	}
+	missing := MissingInRange(u, 0, 255)
+	require.Len(t, missing, 251, testInfo)
+	require.Equal(t, uint8(2), missing[0], testInfo)
+	require.Equal(t, uint8(253), missing[len(missing)-1], testInfo)
+	u.Delete(255)
+	require.Equal(t, []uint8{253, 255}, MissingInRange(u, 253, 255), testInfo)
+}
+
+func ExampleStringBTree() {
+	b := NewStringBTree(2)
+	for _, s := range []string{"pear", "apple", "fig", "apple"} {
+		b.Insert(s)
+	}
+	_, ok := b.Search("fig")
+	fmt.Println(b.Len(), ok, b.ToSlice())
+	// Output: 3 true [apple fig pear]
+}
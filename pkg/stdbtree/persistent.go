@@ -0,0 +1,74 @@
+package stdbtree
+
+import "sync/atomic"
+
+// lastGen is the most recently handed out tree generation. Plain BTrees all
+// use generation 0 since they never share nodes.
+var lastGen uint64
+
+func nextGen() uint64 {
+	return atomic.AddUint64(&lastGen, 1)
+}
+
+// PersistentBTree is an immutable BTree: Insert and Delete leave the receiver
+// unchanged and return a new version of the tree, so every earlier version
+// remains a valid snapshot.
+//
+// Versions share structure. Each version owns the nodes created under its
+// generation, all other nodes are treated as read-only and are copied lazily
+// the first time a write path needs to modify them. An update therefore only
+// copies the O(height) nodes it touches (plus the siblings involved in a
+// delete fix-up) and taking a new version is O(1).
+type PersistentBTree struct {
+	tree *BTree
+}
+
+// NewPersistentBTree creates an empty PersistentBTree with minimum degree t,
+// see NewBTree for the constraints on t.
+func NewPersistentBTree(t int) *PersistentBTree {
+	return &PersistentBTree{tree: NewBTree(t)}
+}
+
+// next returns a new version of the tree sharing all of p's nodes.
+func (p *PersistentBTree) next() *BTree {
+	c := *p.tree
+	c.gen = nextGen()
+	return &c
+}
+
+// Insert returns a new version of the tree with item added, replacing any
+// equal item.
+func (p *PersistentBTree) Insert(item Item) *PersistentBTree {
+	c := p.next()
+	c.Insert(item)
+	return &PersistentBTree{tree: c}
+}
+
+// Delete returns a new version of the tree without the item equal to item. If
+// there is no such item p itself is returned.
+func (p *PersistentBTree) Delete(item Item) *PersistentBTree {
+	if p.tree.Search(item) == nil {
+		return p
+	}
+	c := p.next()
+	c.Delete(item)
+	return &PersistentBTree{tree: c}
+}
+
+// Search returns the stored item equal to item, or nil if there is none.
+func (p *PersistentBTree) Search(item Item) Item {
+	return p.tree.Search(item)
+}
+
+// Len returns the number of items in this version of the tree.
+func (p *PersistentBTree) Len() int {
+	return p.tree.Len()
+}
+
+// Clone returns an ordinary mutable BTree holding the same items as this
+// version in O(1). The BTree shares p's nodes copy-on-write, so mutating it
+// leaves p untouched. Use it for any read-only query not offered directly by
+// PersistentBTree as well.
+func (p *PersistentBTree) Clone() *BTree {
+	return p.next()
+}
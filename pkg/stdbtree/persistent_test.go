@@ -0,0 +1,133 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 100
+	T := rand.Intn(4) + 2                                         // [2,5]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// keep every version along with the items it is expected to hold
+	versions := []*PersistentBTree{NewPersistentBTree(T)}
+	expected := [][]Item{{}}
+	present := make(map[numItem]bool)
+	for i := 0; i < 10*N; i++ {
+		num := numItem(rand.Intn(N))
+		curr := versions[len(versions)-1]
+		var next *PersistentBTree
+		if rand.Intn(3) > 0 {
+			next = curr.Insert(num)
+			present[num] = true
+		} else {
+			next = curr.Delete(num)
+			delete(present, num)
+		}
+		items := []Item{}
+		for j := 0; j < N; j++ {
+			if present[numItem(j)] {
+				items = append(items, numItem(j))
+			}
+		}
+		require.Equal(t, len(items), next.Len(), testInfo)
+		versions = append(versions, next)
+		expected = append(expected, items)
+	}
+
+	// no later update is visible through an earlier version
+	for i, v := range versions {
+		c := v.Clone()
+		require.NoError(t, checkInvariances(c, len(expected[i])), testInfo)
+		require.Equal(t, expected[i], c.ToSlice(), testInfo)
+		for _, item := range expected[i] {
+			require.NotNil(t, v.Search(item), testInfo)
+		}
+	}
+
+	// mutating a clone leaves the version it came from untouched
+	last := versions[len(versions)-1]
+	c := last.Clone()
+	for i := 0; i < N; i++ {
+		c.Delete(numItem(i))
+	}
+	require.Equal(t, 0, c.Len(), testInfo)
+	require.Equal(t, expected[len(expected)-1], last.Clone().ToSlice(), testInfo)
+}
+
+// nodeIdentitySet returns the set of nodes reachable from b's root, so that
+// tests can tell how many nodes two versions of a tree share.
+func (b *BTree) nodeIdentitySet() map[*node]struct{} {
+	set := make(map[*node]struct{})
+	var visit func(n *node)
+	visit = func(n *node) {
+		set[n] = struct{}{}
+		if !n.isLeaf {
+			for i := 0; i <= n.n; i++ {
+				visit(n.children[i])
+			}
+		}
+	}
+	visit(b.root)
+	return set
+}
+
+// notIn returns the no. of nodes in a that are not in b.
+func notIn(a, b map[*node]struct{}) (count int) {
+	for n := range a {
+		if _, ok := b[n]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPersistentBTreeSharing(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 1000
+	T := rand.Intn(4) + 2                                         // [2,5]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	p := NewPersistentBTree(T)
+	for _, i := range rand.Perm(N) {
+		p = p.Insert(numItem(2 * i))
+	}
+	before := p.tree.nodeIdentitySet()
+
+	// taking a new version copies nothing, nor does a Replace that misses,
+	// while one that hits copies just the path to the item
+	c := p.Clone()
+	require.Zero(t, notIn(c.nodeIdentitySet(), before), testInfo)
+	_, ok := c.Replace(numItem(1))
+	require.False(t, ok, testInfo)
+	require.Zero(t, notIn(c.nodeIdentitySet(), before), testInfo)
+	_, ok = c.Replace(numItem(0))
+	require.True(t, ok, testInfo)
+	require.Equal(t, len(c.searchPath(numItem(0))), notIn(c.nodeIdentitySet(), before), testInfo)
+
+	for i := 0; i < 100; i++ {
+		height := p.tree.Height()
+		var q *PersistentBTree
+		if i%2 == 0 {
+			// an insert copies the path down to the leaf and may add a
+			// node per split plus a new root
+			q = p.Insert(numItem(2*rand.Intn(N) + 1))
+			require.LessOrEqual(t, notIn(q.tree.nodeIdentitySet(), before), 2*height+1, testInfo)
+		} else {
+			// a delete copies the path and at most one sibling per level
+			q = p.Delete(numItem(2 * rand.Intn(N)))
+			require.LessOrEqual(t, notIn(q.tree.nodeIdentitySet(), before), 2*height, testInfo)
+		}
+		// the earlier version is left exactly as it was
+		require.Equal(t, before, p.tree.nodeIdentitySet(), testInfo)
+		p, before = q, q.tree.nodeIdentitySet()
+	}
+}
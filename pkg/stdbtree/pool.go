@@ -0,0 +1,80 @@
+package stdbtree
+
+import "sync"
+
+// nodePool allocates the nodes of a tree of a single minimum degree. If
+// recycle is set freed nodes are kept for reuse, leaves and internal nodes
+// being pooled separately so that a recycled node has exactly the slices
+// newNode would have allocated for it. If compact is set leaves start out
+// with room for only leafCap items, see NewCompactBTree and
+// NewBTreeWithCapacity. A nil *nodePool is valid, it allocates fresh nodes and
+// drops freed ones.
+type nodePool struct {
+	recycle   bool
+	compact   bool
+	leafCap   int
+	leaves    sync.Pool
+	internals sync.Pool
+}
+
+func newNodePool(t int) *nodePool {
+	p := &nodePool{recycle: true}
+	p.leaves.New = func() interface{} { return newNode(t, true) }
+	p.internals.New = func() interface{} { return newNode(t, false) }
+	return p
+}
+
+// withDegree returns a pool with p's settings for nodes of minimum degree t,
+// the leaf capacity being clamped to the new 2t-1.
+func (p *nodePool) withDegree(t int) *nodePool {
+	switch {
+	case p == nil:
+		return nil
+	case p.recycle:
+		return newNodePool(t)
+	}
+	leafCap := p.leafCap
+	if leafCap > 2*t-1 {
+		leafCap = 2*t - 1
+	}
+	return &nodePool{compact: p.compact, leafCap: leafCap}
+}
+
+func (p *nodePool) newNode(t int, isLeaf bool) *node {
+	switch {
+	case p == nil:
+		return newNode(t, isLeaf)
+	case p.recycle && isLeaf:
+		return p.leaves.Get().(*node)
+	case p.recycle:
+		return p.internals.Get().(*node)
+	case p.compact && isLeaf:
+		// further room is allocated by reserve as items are inserted
+		n := &node{isLeaf: true}
+		if p.leafCap > 0 {
+			n.items = make([]Item, p.leafCap)
+		}
+		return n
+	}
+	return newNode(t, isLeaf)
+}
+
+// free resets n and returns it to the pool. n must no longer be reachable from
+// any tree.
+func (p *nodePool) free(n *node) {
+	if p == nil || !p.recycle {
+		return
+	}
+	for i := range n.items {
+		n.items[i] = nil
+	}
+	for i := range n.children {
+		n.children[i] = nil
+	}
+	n.n, n.size, n.gen = 0, 0, 0
+	if n.isLeaf {
+		p.leaves.Put(n)
+	} else {
+		p.internals.Put(n)
+	}
+}
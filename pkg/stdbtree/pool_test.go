@@ -0,0 +1,62 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBTreeWithPool(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// recycled nodes must come back fully reset, so churn through several
+	// rounds of filling and draining the tree
+	b := NewBTreeWithPool(T)
+	present := make(map[numItem]bool)
+	for i := 0; i < 20*N; i++ {
+		num := numItem(rand.Intn(N))
+		if rand.Intn(2) == 0 {
+			b.Insert(num)
+			present[num] = true
+		} else {
+			b.Delete(num)
+			delete(present, num)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+	for num := range present {
+		require.NotNil(t, b.Search(num), testInfo)
+	}
+}
+
+// BenchmarkChurn and BenchmarkChurnWithPool compare allocations for a workload
+// of interleaved inserts and deletes, which frees nodes through merges
+func benchmarkChurn(b *testing.B, newTree func(t int) *BTree) {
+	nums := rand.Perm(10000)
+	tree := newTree(4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, num := range nums {
+			tree.Insert(numItem(num))
+		}
+		for _, num := range nums {
+			tree.Delete(numItem(num))
+		}
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	benchmarkChurn(b, NewBTree)
+}
+
+func BenchmarkChurnWithPool(b *testing.B) {
+	benchmarkChurn(b, NewBTreeWithPool)
+}
@@ -0,0 +1,51 @@
+package stdbtree
+
+// PriorityQueue is a min priority queue backed by a multiset BTree, an
+// alternative to container/heap that keeps its items fully ordered. Items are
+// prioritized by their Compare, smallest first, and items of equal priority
+// may be pushed side by side. Push and PopMin take O(log n), PeekMin
+// O(height), and Update changes an item's priority in O(log n) as a delete
+// followed by an insert.
+type PriorityQueue struct {
+	tree *BTree
+}
+
+// NewPriorityQueue creates an empty PriorityQueue over a tree with minimum
+// degree t, see NewBTree for the constraints on t.
+func NewPriorityQueue(t int) *PriorityQueue {
+	return &PriorityQueue{tree: NewMultiBTree(t)}
+}
+
+// Push adds item to the queue.
+func (q *PriorityQueue) Push(item Item) {
+	q.tree.Insert(item)
+}
+
+// PopMin removes and returns the item with the smallest priority, or returns
+// nil if the queue is empty.
+func (q *PriorityQueue) PopMin() Item {
+	return q.tree.DeleteMin()
+}
+
+// PeekMin returns the item with the smallest priority without removing it, or
+// nil if the queue is empty.
+func (q *PriorityQueue) PeekMin() Item {
+	return q.tree.Min()
+}
+
+// Update replaces old with updated, which may carry a different priority, and
+// reports whether old was in the queue; if not the queue is left unchanged.
+// As with Delete on a multiset, any one item equal to old is the one
+// replaced, so items that must be told apart should not compare equal.
+func (q *PriorityQueue) Update(old, updated Item) bool {
+	if q.tree.Delete(old) == nil {
+		return false
+	}
+	q.tree.Insert(updated)
+	return true
+}
+
+// Len returns the number of items in the queue.
+func (q *PriorityQueue) Len() int {
+	return q.tree.Len()
+}
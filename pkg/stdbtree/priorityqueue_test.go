@@ -0,0 +1,55 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	q := NewPriorityQueue(T)
+	require.Nil(t, q.PopMin(), testInfo)
+	require.Nil(t, q.PeekMin(), testInfo)
+	require.Equal(t, 0, q.Len(), testInfo)
+
+	// heap sort a shuffled slice with duplicates
+	nums := make([]int, N)
+	for i := range nums {
+		nums[i] = rand.Intn(N / 2)
+	}
+	for _, num := range nums {
+		q.Push(numItem(num))
+	}
+	require.Equal(t, N, q.Len(), testInfo)
+	sorted := make([]int, 0, N)
+	for q.Len() > 0 {
+		next := q.PeekMin()
+		require.Equal(t, next, q.PopMin(), testInfo)
+		sorted = append(sorted, int(next.(numItem)))
+	}
+	sort.Ints(nums)
+	require.Equal(t, nums, sorted, testInfo)
+	require.Nil(t, q.PopMin(), testInfo)
+
+	// decrease and increase key
+	for i := 0; i < N; i++ {
+		q.Push(numItem(N + i))
+	}
+	require.True(t, q.Update(numItem(N+N/2), numItem(0)), testInfo)
+	require.Equal(t, numItem(0), q.PeekMin(), testInfo)
+	require.True(t, q.Update(numItem(0), numItem(3*N)), testInfo)
+	require.Equal(t, numItem(N), q.PeekMin(), testInfo)
+	require.False(t, q.Update(numItem(-1), numItem(0)), testInfo)
+	require.Equal(t, N, q.Len(), testInfo)
+	require.NoError(t, checkInvariances(q.tree, N), testInfo)
+}
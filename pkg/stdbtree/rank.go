@@ -0,0 +1,123 @@
+package stdbtree
+
+// Every node tracks the no. of items in its subtree, maintained through
+// insert, splitChild and the delete fix-ups, which lets the tree answer order
+// statistic queries in O(height).
+
+// Select returns the kth smallest item in the tree, counting from 0, or nil if
+// k is out of range.
+func (b *BTree) Select(k int) Item {
+	if k < 0 || k >= b.len {
+		return nil
+	}
+	n := b.root
+	for {
+		var i int
+		for i = 0; i < n.n; i++ {
+			cs := n.childSize(i)
+			if k < cs {
+				break
+			}
+			k -= cs
+			if k == 0 {
+				return n.items[i]
+			}
+			k--
+		}
+		// k falls within children[i]
+		n = n.children[i]
+	}
+}
+
+// At returns the item at position index in ascending order, counting from 0,
+// and whether index is in range. It walks the items in order and stops once
+// it gets there, taking O(index) without using the subtree sizes, so it also
+// serves as a reference for Select, which uses them to do the same in
+// O(height) and should be preferred.
+func (b *BTree) At(index int) (item Item, ok bool) {
+	if index < 0 || index >= b.len {
+		return nil, false
+	}
+	b.ForEach(func(x Item) bool {
+		if index == 0 {
+			item, ok = x, true
+			return false
+		}
+		index--
+		return true
+	})
+	return item, ok
+}
+
+// Rank returns the no. of items in the tree strictly less than item. item need
+// not be present in the tree.
+func (b *BTree) Rank(item Item) int {
+	return b.rank(item, false)
+}
+
+// SearchPos returns the stored item equal to item, or nil if there is none,
+// along with the rank item has or would have were it inserted: the no. of
+// items strictly less than it. It answers both in a single descent. In a
+// multiset tree found is one of the equal items and rank counts none of them.
+func (b *BTree) SearchPos(item Item) (found Item, rank int) {
+	n := b.root
+	for {
+		i, ok := n.find(b.cmp, item)
+		rank += i
+		for j := 0; j < i; j++ {
+			rank += n.childSize(j)
+		}
+		if ok {
+			found = n.items[i]
+			if !b.multi {
+				// children[i] lies left of items[i], all less than item
+				return found, rank + n.childSize(i)
+			}
+			// equal items may also sit in children[i], keep going
+		}
+		if n.isLeaf {
+			return found, rank
+		}
+		n = n.children[i]
+	}
+}
+
+// rank returns the no. of items less than item, or less than or equal to it if
+// inclusive is set.
+func (b *BTree) rank(item Item, inclusive bool) (rank int) {
+	n := b.root
+	for {
+		var i int
+		if inclusive {
+			i = n.upperBound(b.cmp, item)
+		} else {
+			i, _ = n.find(b.cmp, item)
+		}
+		// items[:i] and the subtrees to their left are all less than item
+		rank += i
+		for j := 0; j < i; j++ {
+			rank += n.childSize(j)
+		}
+		if n.isLeaf {
+			return rank
+		}
+		n = n.children[i]
+	}
+}
+
+// RangeCount returns the no. of items x in the tree with lo <= x <= hi, a nil
+// bound being open. It uses the subtree sizes to answer in O(height) without
+// visiting the items in the range.
+func (b *BTree) RangeCount(lo, hi Item) int {
+	below, upTo := 0, b.len
+	if lo != nil {
+		below = b.rank(lo, false)
+	}
+	if hi != nil {
+		upTo = b.rank(hi, true)
+	}
+	if upTo < below { // lo > hi
+		return 0
+	}
+	return upTo - below
+}
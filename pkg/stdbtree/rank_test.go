@@ -0,0 +1,160 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectRank(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Nil(t, b.Select(0), testInfo)
+	require.Equal(t, 0, b.Rank(numItem(0)), testInfo)
+
+	check := func() {
+		items := b.ToSlice()
+		for k, item := range items {
+			require.Equal(t, item, b.Select(k), testInfo, k)
+			require.Equal(t, k, b.Rank(item), testInfo, item)
+		}
+		require.Nil(t, b.Select(-1), testInfo)
+		require.Nil(t, b.Select(len(items)), testInfo)
+	}
+
+	// store even numbers so absent probes fall between items
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	check()
+	for i := -1; i < 2*N+1; i++ {
+		require.Equal(t, (i+1)/2, b.Rank(numItem(i)), testInfo, i)
+	}
+
+	// counts stay correct across merges and borrows
+	for _, i := range rand.Perm(N)[:N/2] {
+		b.Delete(numItem(2 * i))
+		require.NoError(t, checkInvariances(b, b.Len()), testInfo)
+	}
+	check()
+
+	// and across bulk loading
+	b, err := NewBTreeFromSorted(T, b.ToSlice())
+	require.NoError(t, err, testInfo)
+	require.NoError(t, checkInvariances(b, N-N/2), testInfo)
+	check()
+}
+
+func TestSearchPos(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	found, rank := b.SearchPos(numItem(0))
+	require.Nil(t, found, testInfo)
+	require.Zero(t, rank, testInfo)
+
+	// store even numbers so absent probes fall between items
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	for _, i := range rand.Perm(N)[:N/3] {
+		b.Delete(numItem(2 * i))
+	}
+	items := b.ToSlice()
+	for i := -1; i < 2*N+1; i++ {
+		found, rank := b.SearchPos(numItem(i))
+		require.Equal(t, b.Search(numItem(i)), found, testInfo, i)
+		require.Equal(t, b.Rank(numItem(i)), rank, testInfo, i)
+		if found != nil {
+			require.Equal(t, found, items[rank], testInfo, i)
+		}
+	}
+
+	// in a multiset rank counts none of the equal items
+	m := NewMultiBTree(T)
+	for _, i := range rand.Perm(N) {
+		m.Insert(&idItem{key: i % 20, id: i})
+	}
+	for key := -1; key <= 20; key++ {
+		found, rank := m.SearchPos(&idItem{key: key})
+		require.Equal(t, key >= 0 && key < 20, found != nil, testInfo, key)
+		if found != nil {
+			require.Equal(t, key, found.(*idItem).key, testInfo)
+		}
+		require.Equal(t, m.Rank(&idItem{key: key}), rank, testInfo, key)
+	}
+}
+
+func TestRangeCount(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, 0, b.RangeCount(nil, nil), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	for i := 0; i < 500; i++ {
+		var lo, hi Item
+		if rand.Intn(10) > 0 {
+			lo = numItem(rand.Intn(2*N+20) - 10)
+		}
+		if rand.Intn(10) > 0 {
+			hi = numItem(rand.Intn(2*N+20) - 10)
+		}
+		info := fmt.Sprintf("%s [lo = %v, hi = %v]", testInfo, lo, hi)
+		require.Equal(t, len(b.RangeScan(lo, hi)), b.RangeCount(lo, hi), info)
+	}
+
+	// in a multiset every equal item is counted
+	m := NewMultiBTree(T)
+	for i := 0; i < 5; i++ {
+		m.Insert(numItem(1))
+		m.Insert(numItem(i))
+	}
+	require.Equal(t, 6, m.RangeCount(numItem(1), numItem(1)), testInfo)
+	require.Equal(t, 7, m.RangeCount(numItem(0), numItem(1)), testInfo)
+}
+
+func TestAt(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	_, ok := b.At(0)
+	require.False(t, ok, testInfo)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(3 * i))
+	}
+	for i := 0; i < N; i++ {
+		item, ok := b.At(i)
+		require.True(t, ok, testInfo)
+		require.Equal(t, numItem(3*i), item, testInfo)
+		require.Equal(t, b.Select(i), item, testInfo)
+	}
+	for _, i := range []int{-1, N, N + 1} {
+		item, ok := b.At(i)
+		require.False(t, ok, testInfo)
+		require.Nil(t, item, testInfo)
+	}
+}
@@ -0,0 +1,219 @@
+package stdbtree
+
+import (
+	"context"
+	"fmt"
+)
+
+// ascendRange calls fn in ascending order for every item x in the subtree
+// rooted at n with lo <= x <= hi, a nil bound being open. Subtrees that lie
+// entirely outside the range are never visited. It returns false as soon as
+// fn returns false or an item beyond hi is reached, signalling the caller
+// that the walk is over.
+func (n *node) ascendRange(cmp func(a, b Item) int, lo, hi Item, fn func(Item) bool) bool {
+	var i int
+	if lo != nil {
+		// everything before items[i] is less than lo. children[i] may still
+		// hold items equal to lo in a multiset tree so it is not skipped.
+		i, _ = n.find(cmp, lo)
+	}
+	for ; i < n.n; i++ {
+		if !n.isLeaf {
+			if !n.children[i].ascendRange(cmp, lo, hi, fn) {
+				return false
+			}
+		}
+		if hi != nil && cmp(hi, n.items[i]) == lessThan {
+			return false
+		}
+		if !fn(n.items[i]) {
+			return false
+		}
+	}
+	if !n.isLeaf {
+		return n.children[n.n].ascendRange(cmp, lo, hi, fn)
+	}
+	return true
+}
+
+// RangeScan returns, in ascending order, every item x in the tree with
+// lo <= x <= hi. Either bound may be nil to leave that end of the range open.
+func (b *BTree) RangeScan(lo, hi Item) []Item {
+	var items []Item
+	b.root.ascendRange(b.cmp, lo, hi, func(item Item) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+// RangeForEach calls fn for every item x in the tree with lo <= x <= hi in
+// ascending order, a nil bound being open, stopping as soon as fn returns
+// false. It is the streaming form of RangeScan: subtrees outside the range are
+// pruned and no result slice is allocated.
+func (b *BTree) RangeForEach(lo, hi Item, fn func(item Item) bool) {
+	b.root.ascendRange(b.cmp, lo, hi, fn)
+}
+
+// ForEach calls fn for every item in the tree in ascending order, stopping as
+// soon as fn returns false.
+func (b *BTree) ForEach(fn func(item Item) bool) {
+	b.root.ascendRange(b.cmp, nil, nil, fn)
+}
+
+// SortedChan streams every item in the tree in ascending order over a channel
+// with a buffer of bufSize items, which is closed after the last one. The
+// items are produced by a goroutine walking the tree, so at most bufSize+1 of
+// them are held beyond the tree at any time.
+//
+// The walk reads the tree as it goes: the tree must not be modified until the
+// channel is closed. A consumer that stops receiving early must cancel ctx,
+// after which the producer stops and closes the channel; otherwise the
+// goroutine stays blocked on the send forever.
+func (b *BTree) SortedChan(ctx context.Context, bufSize int) <-chan Item {
+	ch := make(chan Item, bufSize)
+	go func() {
+		defer close(ch)
+		b.ForEach(func(item Item) bool {
+			if ctx.Err() != nil {
+				// select picks at random when both cases are ready
+				return false
+			}
+			select {
+			case ch <- item:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return ch
+}
+
+// MapInPlace replaces every item x in the tree with fn(x), visiting them in
+// ascending order, e.g. to update the values carried alongside the keys. fn
+// must not change the relative order of the items: its results must be in
+// the same strictly ascending order (non-descending in a multiset) as the
+// items they replace. This is checked as the walk goes, and an error
+// describes the first violation. The tree is nonetheless mapped in full, any
+// damage to its order reported by Validate and undone by Rebuild.
+func (b *BTree) MapInPlace(fn func(item Item) Item) error {
+	var prev Item
+	var err error
+	var walk func(n *node)
+	walk = func(n *node) {
+		for i := 0; i <= n.n; i++ {
+			if !n.isLeaf {
+				walk(n.mutableChild(i))
+			}
+			if i == n.n {
+				break
+			}
+			n.items[i] = fn(n.items[i])
+			if err == nil && prev != nil {
+				switch c := b.cmp(prev, n.items[i]); {
+				case c == greaterThan, c == equal && !b.multi:
+					err = fmt.Errorf("mapped %w: %v comes before %v", ErrOutOfOrder, prev, n.items[i])
+				}
+			}
+			prev = n.items[i]
+		}
+	}
+	b.mods++
+	b.root = b.root.mutableFor(b.gen)
+	walk(b.root)
+	// the items are new, and may not hash like the ones they replaced
+	b.rebuildBloom()
+	return err
+}
+
+// ToSlice returns every item in the tree in ascending order.
+func (b *BTree) ToSlice() []Item {
+	items := make([]Item, 0, b.len)
+	b.ForEach(func(item Item) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+// Count returns the number of items in the tree equal to item. This is at most
+// 1 unless the tree is a multiset.
+func (b *BTree) Count(item Item) int {
+	var count int
+	b.root.ascendRange(b.cmp, item, item, func(Item) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// DeleteRange removes every item x in the tree with lo <= x <= hi, a nil bound
+// being open, and returns how many were removed. The items are collected first
+// and then deleted one by one so the tree rebalances as usual.
+func (b *BTree) DeleteRange(lo, hi Item) int {
+	items := b.RangeScan(lo, hi)
+	for _, item := range items {
+		b.Delete(item)
+	}
+	return len(items)
+}
+
+// DeleteIf removes every item for which pred returns true and returns how
+// many were removed. The matching items are collected in an in-order walk and
+// then deleted one by one so the tree rebalances as usual. In a multiset,
+// where deleting an item may remove any of the items equal to it, the tree is
+// instead rebuilt from the items that are kept.
+func (b *BTree) DeleteIf(pred func(item Item) bool) int {
+	var matched, kept []Item
+	b.ForEach(func(item Item) bool {
+		if pred(item) {
+			matched = append(matched, item)
+		} else if b.multi {
+			kept = append(kept, item)
+		}
+		return true
+	})
+	if len(matched) == 0 {
+		return 0
+	}
+	if b.multi {
+		b.Clear()
+		b.InsertMany(kept)
+		return len(matched)
+	}
+	for _, item := range matched {
+		b.Delete(item)
+	}
+	return len(matched)
+}
+
+// PrefixScan returns, in ascending order, every item x in the tree for which
+// hasPrefix(x, prefix) holds, e.g. all the strings starting with a given
+// string for autocomplete. It relies on the items with a prefix forming a
+// contiguous run that starts at prefix itself, as is the case for strings in
+// lexicographic order: the walk begins at prefix, skipping the subtrees
+// before it, and stops at the first item without the prefix.
+func (b *BTree) PrefixScan(prefix Item, hasPrefix func(item, prefix Item) bool) []Item {
+	var items []Item
+	b.root.ascendRange(b.cmp, prefix, nil, func(item Item) bool {
+		if !hasPrefix(item, prefix) {
+			return false
+		}
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+// FoldRange folds fn over every item x in b with lo <= x <= hi in ascending
+// order, a nil bound being open, starting from acc and returning the final
+// accumulator. Subtrees outside the range are pruned and no intermediate slice
+// is allocated.
+func FoldRange[A any](b *BTree, lo, hi Item, acc A, fn func(acc A, item Item) A) A {
+	b.root.ascendRange(b.cmp, lo, hi, func(item Item) bool {
+		acc = fn(acc, item)
+		return true
+	})
+	return acc
+}
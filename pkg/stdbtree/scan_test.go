@@ -0,0 +1,443 @@
+package stdbtree
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeScan(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.RangeScan(nil, nil), testInfo)
+
+	// store only even numbers so that bounds fall both on and between items
+	var sorted []numItem
+	for i := 0; i < N; i += 2 {
+		sorted = append(sorted, numItem(i))
+	}
+	for _, i := range rand.Perm(len(sorted)) {
+		b.Insert(sorted[i])
+	}
+
+	// brute force filter over the sorted items
+	bruteForce := func(lo, hi Item) []Item {
+		var items []Item
+		for _, num := range sorted {
+			if lo != nil && lo.Compare(num) == greaterThan {
+				continue
+			}
+			if hi != nil && hi.Compare(num) == lessThan {
+				continue
+			}
+			items = append(items, num)
+		}
+		return items
+	}
+
+	for i := 0; i < 500; i++ {
+		var lo, hi Item
+		if rand.Intn(10) > 0 {
+			lo = numItem(rand.Intn(N+20) - 10)
+		}
+		if rand.Intn(10) > 0 {
+			hi = numItem(rand.Intn(N+20) - 10)
+		}
+		info := fmt.Sprintf("%s [lo = %v, hi = %v]", testInfo, lo, hi)
+		require.Equal(t, bruteForce(lo, hi), b.RangeScan(lo, hi), info)
+	}
+	require.Len(t, b.RangeScan(nil, nil), len(sorted), testInfo)
+}
+
+func TestRangeForEach(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	b.RangeForEach(nil, nil, func(item Item) bool {
+		t.Fatal("fn called on empty tree")
+		return true
+	})
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	for i := 0; i < 200; i++ {
+		var lo, hi Item
+		if rand.Intn(10) > 0 {
+			lo = numItem(rand.Intn(N+20) - 10)
+		}
+		if rand.Intn(10) > 0 {
+			hi = numItem(rand.Intn(N+20) - 10)
+		}
+		info := fmt.Sprintf("%s [lo = %v, hi = %v]", testInfo, lo, hi)
+		expected := b.RangeScan(lo, hi)
+
+		// visits the same items as RangeScan
+		var visited []Item
+		b.RangeForEach(lo, hi, func(item Item) bool {
+			visited = append(visited, item)
+			return true
+		})
+		require.Equal(t, expected, visited, info)
+
+		// and stops right after fn returns false
+		limit := rand.Intn(10) + 1
+		var calls int
+		b.RangeForEach(lo, hi, func(item Item) bool {
+			require.Equal(t, expected[calls], item, info)
+			calls++
+			return calls < limit
+		})
+		if limit > len(expected) {
+			limit = len(expected)
+		}
+		require.Equal(t, limit, calls, info)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	b.ForEach(func(item Item) bool {
+		t.Fatal("fn called on empty tree")
+		return true
+	})
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	// visits every item in order
+	var visited []Item
+	b.ForEach(func(item Item) bool {
+		visited = append(visited, item)
+		return true
+	})
+	require.Len(t, visited, N, testInfo)
+	for i, item := range visited {
+		require.Equal(t, numItem(i), item, testInfo)
+	}
+
+	// stops once fn returns false at the 5th item
+	var calls int
+	b.ForEach(func(item Item) bool {
+		calls++
+		return calls < 5
+	})
+	require.Equal(t, 5, calls, testInfo)
+}
+
+func TestSortedChan(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for range b.SortedChan(context.Background(), 0) {
+		t.Fatal("item sent for empty tree")
+	}
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	// receives every item in ascending order
+	for _, bufSize := range []int{0, 1, 16, N} {
+		var received []Item
+		for item := range b.SortedChan(context.Background(), bufSize) {
+			received = append(received, item)
+		}
+		require.Equal(t, b.ToSlice(), received, testInfo, bufSize)
+	}
+
+	// a consumer abandoning the scan cancels, the producer then stops and
+	// closes the channel after at most what it had buffered or was sending
+	bufSize := 4
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := b.SortedChan(ctx, bufSize)
+	for i := 0; i < 5; i++ {
+		require.Equal(t, numItem(i), <-ch, testInfo)
+	}
+	cancel()
+	var rest int
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				rest++
+			}
+			closed = !ok
+		case <-timeout:
+			t.Fatal("channel not closed after cancel", testInfo)
+		}
+	}
+	require.LessOrEqual(t, rest, bufSize+1, testInfo)
+}
+
+func TestToSlice(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Empty(t, b.ToSlice(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	for i := 0; i < N; i += 3 {
+		b.Delete(numItem(i))
+	}
+	items := b.ToSlice()
+	require.Len(t, items, b.Len(), testInfo)
+	for i := 1; i < len(items); i++ {
+		require.Equal(t, lessThan, items[i-1].Compare(items[i]), testInfo)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	present := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		// refill a little so the tree doesn't just run dry
+		for j := 0; j < 10; j++ {
+			num := rand.Intn(N)
+			b.Insert(numItem(num))
+			present[num] = true
+		}
+		switch rand.Intn(3) {
+		case 0:
+			num := rand.Intn(N)
+			b.Delete(numItem(num))
+			delete(present, num)
+		default:
+			lo, hi := rand.Intn(N), rand.Intn(N)
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			var want int
+			for num := lo; num <= hi; num++ {
+				if present[num] {
+					want++
+					delete(present, num)
+				}
+			}
+			info := fmt.Sprintf("%s [lo = %d, hi = %d]", testInfo, lo, hi)
+			require.Equal(t, want, b.DeleteRange(numItem(lo), numItem(hi)), info)
+		}
+		require.NoError(t, checkInvariances(b, len(present)), testInfo)
+	}
+
+	// open bounds empty the tree
+	require.Equal(t, len(present), b.DeleteRange(nil, nil), testInfo)
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+}
+
+func TestFoldRange(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	sum := func(acc int, item Item) int { return acc + int(item.(numItem)) }
+	for i := 0; i < 100; i++ {
+		lo, hi := rand.Intn(N), rand.Intn(N)
+		var want int
+		for num := lo; num <= hi; num++ {
+			want += num
+		}
+		require.Equal(t, want, FoldRange(b, numItem(lo), numItem(hi), 0, sum), testInfo)
+	}
+	require.Equal(t, N*(N-1)/2, FoldRange(b, nil, nil, 0, sum), testInfo)
+
+	// the accumulator may be any type, e.g. the max in range
+	max := FoldRange(b, numItem(10), numItem(20), Item(nil), func(acc Item, item Item) Item {
+		return item
+	})
+	require.Equal(t, numItem(20), max, testInfo)
+}
+
+func ExampleFoldRange() {
+	b := NewBTree(2)
+	for i := 1; i <= 10; i++ {
+		b.Insert(numItem(i))
+	}
+	// sum of the items in [3, 6]
+	sum := FoldRange(b, numItem(3), numItem(6), 0, func(acc int, item Item) int {
+		return acc + int(item.(numItem))
+	})
+	fmt.Println(sum)
+	// Output: 18
+}
+
+type strItem string
+
+func (s strItem) Compare(other Item) int {
+	return strings.Compare(string(s), string(other.(strItem)))
+}
+
+func strHasPrefix(item, prefix Item) bool {
+	return strings.HasPrefix(string(item.(strItem)), string(prefix.(strItem)))
+}
+
+func TestPrefixScan(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// random words over a small alphabet so that prefixes are shared
+	randWord := func() strItem {
+		w := make([]byte, rand.Intn(5)+1)
+		for i := range w {
+			w[i] = "abc"[rand.Intn(3)]
+		}
+		return strItem(w)
+	}
+	b := NewBTree(T)
+	require.Empty(t, b.PrefixScan(strItem("a"), strHasPrefix), testInfo)
+	for i := 0; i < N; i++ {
+		b.Insert(randWord())
+	}
+	all := b.ToSlice()
+	for _, prefix := range []strItem{"", "a", "ab", "cab", "bbbb", "d", randWord(), randWord()} {
+		var expected []Item
+		for _, item := range all {
+			if strHasPrefix(item, prefix) {
+				expected = append(expected, item)
+			}
+		}
+		require.Equal(t, expected, b.PrefixScan(prefix, strHasPrefix), "%s prefix %q", testInfo, prefix)
+	}
+}
+
+func ExampleBTree_PrefixScan() {
+	b := NewBTree(2)
+	for _, word := range []string{"car", "cart", "cat", "dog", "ca", "cab", "b"} {
+		b.Insert(strItem(word))
+	}
+	fmt.Println(b.PrefixScan(strItem("car"), strHasPrefix))
+	fmt.Println(b.PrefixScan(strItem("ca"), strHasPrefix))
+	// Output:
+	// [car cart]
+	// [ca cab car cart cat]
+}
+
+func TestMapInPlace(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	p := NewPersistentBTree(T)
+	for _, i := range rand.Perm(N) {
+		p = p.Insert(&idItem{key: i, id: i})
+	}
+	// map a new version so that the nodes start out shared with p
+	q := p.Insert(&idItem{key: N, id: N})
+	b := q.tree
+	require.NoError(t, b.MapInPlace(func(item Item) Item {
+		it := item.(*idItem)
+		return &idItem{key: it.key, id: -it.id}
+	}), testInfo)
+	require.NoError(t, checkInvariances(b, N+1), testInfo)
+	for i, item := range b.ToSlice() {
+		require.Equal(t, &idItem{key: i, id: -i}, item, testInfo)
+	}
+	for i, item := range p.tree.ToSlice() {
+		require.Equal(t, &idItem{key: i, id: i}, item, "earlier version untouched %s", testInfo)
+	}
+
+	// equal keys are fine in a multiset but not in a set
+	m := NewMultiBTree(T)
+	s := NewBTree(T)
+	for i := 0; i < N; i++ {
+		m.Insert(numItem(i))
+		s.Insert(numItem(i))
+	}
+	halve := func(item Item) Item { return item.(numItem) / 2 }
+	require.NoError(t, m.MapInPlace(halve), testInfo)
+	require.NoError(t, checkInvariances(m, N), testInfo)
+	require.Error(t, s.MapInPlace(halve), testInfo)
+	require.Error(t, s.Validate(), testInfo)
+
+	// reversing the order is caught, and Validate agrees
+	b = NewBTree(T)
+	for i := 0; i < N; i++ {
+		b.Insert(numItem(i))
+	}
+	require.Error(t, b.MapInPlace(func(item Item) Item { return -item.(numItem) }), testInfo)
+	require.Error(t, b.Validate(), testInfo)
+	b.Rebuild()
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	require.Equal(t, numItem(-(N - 1)), b.Min(), testInfo)
+}
+
+func TestDeleteIf(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	isEven := func(item Item) bool { return item.(numItem)%2 == 0 }
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	require.Equal(t, N/2, b.DeleteIf(isEven), testInfo)
+	require.NoError(t, checkInvariances(b, N/2), testInfo)
+	for i, item := range b.ToSlice() {
+		require.Equal(t, numItem(2*i+1), item, testInfo)
+	}
+	require.Zero(t, b.DeleteIf(isEven), testInfo)
+	require.Equal(t, N/2, b.DeleteIf(func(Item) bool { return true }), testInfo)
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+
+	// in a multiset only the matching ones among equal items go
+	m := NewMultiBTree(T)
+	for i := 0; i < N; i++ {
+		m.Insert(&idItem{key: i % 10, id: i})
+	}
+	require.Equal(t, N/2, m.DeleteIf(func(item Item) bool { return item.(*idItem).id%2 == 0 }), testInfo)
+	require.NoError(t, checkInvariances(m, N/2), testInfo)
+	for _, item := range m.ToSlice() {
+		require.Equal(t, 1, item.(*idItem).id%2, testInfo)
+	}
+}
@@ -0,0 +1,64 @@
+package stdbtree
+
+// infinity is the type of the NegInf and PosInf sentinels, holding lessThan or
+// greaterThan, how it compares to every other item.
+type infinity int
+
+// NegInf and PosInf are sentinel items that come before and after every item
+// in a tree's order, for use as the bounds of an open ended range query in
+// place of nil: RangeScan(NegInf, x), Ceiling(NegInf) and so on. Trees handle
+// them in their comparator, whether the default one or one given to
+// NewBTreeFunc, so the items' own Compare and cmp never see a sentinel. They
+// can only be used to query a tree, inserting one panics.
+var (
+	NegInf Item = infinity(lessThan)
+	PosInf Item = infinity(greaterThan)
+)
+
+// Compare orders the sentinels with NegInf < everything < PosInf.
+func (inf infinity) Compare(other Item) int {
+	if c, ok := compareInfinities(inf, other); ok {
+		return c
+	}
+	return int(inf)
+}
+
+// compareInfinities compares a and b if either is a sentinel, with ok unset if
+// neither is and it falls to the tree's comparator.
+func compareInfinities(a, b Item) (c int, ok bool) {
+	infA, okA := a.(infinity)
+	infB, okB := b.(infinity)
+	switch {
+	case okA && okB:
+		switch {
+		case infA < infB:
+			return lessThan, true
+		case infA > infB:
+			return greaterThan, true
+		}
+		return equal, true
+	case okA:
+		return int(infA), true
+	case okB:
+		return -int(infB), true
+	}
+	return equal, false
+}
+
+// withInfinities wraps cmp so that it also orders the sentinels.
+func withInfinities(cmp func(a, b Item) int) func(a, b Item) int {
+	return func(a, b Item) int {
+		if c, ok := compareInfinities(a, b); ok {
+			return c
+		}
+		return cmp(a, b)
+	}
+}
+
+// checkInsertable panics if item is one of the sentinels, which have no place
+// in a tree.
+func checkInsertable(item Item) {
+	if _, ok := item.(infinity); ok {
+		panic("cannot insert NegInf or PosInf into a btree")
+	}
+}
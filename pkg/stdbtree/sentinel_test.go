@@ -0,0 +1,92 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfinities(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.Equal(t, lessThan, NegInf.Compare(PosInf))
+	require.Equal(t, greaterThan, PosInf.Compare(NegInf))
+	require.Equal(t, equal, NegInf.Compare(NegInf))
+	require.Equal(t, lessThan, NegInf.Compare(numItem(0)))
+	require.Equal(t, greaterThan, PosInf.Compare(numItem(0)))
+
+	// numItem's Compare panics on any other type, so these only pass if the
+	// sentinels never reach it
+	b := NewBTreeWithBloom(T, N, hashNumItem)
+	require.Nil(t, b.Floor(PosInf), testInfo)
+	require.Nil(t, b.Ceiling(NegInf), testInfo)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	items := b.ToSlice()
+	require.Equal(t, items, b.RangeScan(NegInf, PosInf), testInfo)
+	for i := 0; i < N; i++ {
+		x := numItem(2*i + rand.Intn(2))
+		require.Equal(t, b.RangeScan(nil, x), b.RangeScan(NegInf, x), testInfo, x)
+		require.Equal(t, b.RangeScan(x, nil), b.RangeScan(x, PosInf), testInfo, x)
+	}
+	require.Equal(t, b.Min(), b.Ceiling(NegInf), testInfo)
+	require.Equal(t, b.Max(), b.Floor(PosInf), testInfo)
+	require.Nil(t, b.Floor(NegInf), testInfo)
+	require.Nil(t, b.Ceiling(PosInf), testInfo)
+	require.Equal(t, 0, b.Rank(NegInf), testInfo)
+	require.Equal(t, N, b.Rank(PosInf), testInfo)
+	require.Equal(t, N, b.RangeCount(NegInf, PosInf), testInfo)
+	require.Nil(t, b.Search(NegInf), testInfo)
+	require.Nil(t, b.Delete(PosInf), testInfo)
+
+	// the sentinels can't be stored by any of the ways of inserting
+	emptyTree := NewBTree(T)
+	for _, insert := range []func(b *BTree, item Item){
+		func(b *BTree, item Item) { b.Insert(item) },
+		func(b *BTree, item Item) { b.GetOrInsert(item) },
+		func(b *BTree, item Item) { b.InsertPolicy(item, ConflictKeep) },
+		func(b *BTree, item Item) { b.InsertWithFinger(&Finger{}, item) },
+		func(b *BTree, item Item) { b.InsertMany([]Item{numItem(1), item}) },
+	} {
+		for _, tree := range []*BTree{b, emptyTree} {
+			for _, inf := range []Item{NegInf, PosInf} {
+				require.Panics(t, func() { insert(tree, inf) }, testInfo)
+			}
+		}
+	}
+	require.Equal(t, items, b.ToSlice(), testInfo)
+	require.Equal(t, 0, emptyTree.Len(), testInfo)
+
+	// nor bulk loaded
+	for _, inf := range []Item{NegInf, PosInf} {
+		require.Panics(t, func() { NewBTreeFromSorted(T, []Item{inf}) }, testInfo)
+		require.Panics(t, func() { NewBTreeFromSorted(T, []Item{numItem(0), inf}) }, testInfo)
+		bd, err := NewBuilder(T)
+		require.NoError(t, err, testInfo)
+		require.Panics(t, func() { bd.Add(inf) }, testInfo)
+		require.NoError(t, bd.Add(numItem(0)), testInfo)
+		require.Panics(t, func() { bd.Add(inf) }, testInfo)
+		require.NoError(t, bd.Add(numItem(1)), testInfo)
+		require.NoError(t, checkInvariances(bd.Finish(), 2), testInfo)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+
+	// the sentinels are placed by the tree's order rather than their
+	// Compare, so in a reverse tree NegInf still comes first, before the
+	// largest item
+	r := NewReverseBTree(T)
+	for _, item := range items {
+		r.Insert(item)
+	}
+	require.Equal(t, numItem(2*(N-1)), r.Ceiling(NegInf), testInfo)
+	require.Equal(t, numItem(0), r.Floor(PosInf), testInfo)
+	require.Equal(t, r.ToSlice(), r.RangeScan(NegInf, PosInf), testInfo)
+}
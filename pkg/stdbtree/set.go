@@ -0,0 +1,61 @@
+package stdbtree
+
+// Set is an ordered set of values of type T backed by a btree that stores the
+// values inline, so unlike BTree there is no Item interface to implement and
+// no boxing. The zero value is not usable, use NewSet to create one.
+type Set[T any] struct {
+	tree *genericBTree[T]
+}
+
+// NewSet creates an empty Set with minimum degree t whose elements are ordered
+// by cmp, which must return -1, 0 or 1 as a is less than, equal to or greater
+// than b. See NewBTree for the constraints on t, NewSet panics if t is
+// invalid.
+func NewSet[T any](t int, cmp func(a, b T) int) *Set[T] {
+	return &Set[T]{tree: newGenericBTree(t, cmp)}
+}
+
+// Add adds x to the set and reports whether it was newly added. If an equal
+// element is already present it is replaced by x.
+func (s *Set[T]) Add(x T) bool {
+	_, replaced := s.tree.insert(x)
+	return !replaced
+}
+
+// Remove removes the element equal to x from the set and reports whether
+// there was one.
+func (s *Set[T]) Remove(x T) bool {
+	_, ok := s.tree.delete(x)
+	return ok
+}
+
+// Contains reports whether the set holds an element equal to x.
+func (s *Set[T]) Contains(x T) bool {
+	_, ok := s.tree.search(x)
+	return ok
+}
+
+// Len returns the number of elements in the set.
+func (s *Set[T]) Len() int {
+	return s.tree.len
+}
+
+// Min returns the smallest element, ok is unset if the set is empty.
+func (s *Set[T]) Min() (min T, ok bool) {
+	return s.tree.min()
+}
+
+// Max returns the largest element, ok is unset if the set is empty.
+func (s *Set[T]) Max() (max T, ok bool) {
+	return s.tree.max()
+}
+
+// Range returns, in ascending order, every element x with lo <= x <= hi.
+func (s *Set[T]) Range(lo, hi T) []T {
+	var xs []T
+	s.tree.ascendRange(lo, hi, func(x T) bool {
+		xs = append(xs, x)
+		return true
+	})
+	return xs
+}
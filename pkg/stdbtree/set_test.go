@@ -0,0 +1,135 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// checkGenericInvariances checks the generic tree underlying a Set the way
+// Validate does for a BTree.
+func checkGenericInvariances[T any](b *genericBTree[T], expectedLen int) error {
+	var items []T
+	leafDepth := -1
+	var traverseNode func(n *genericNode[T], depth int, isRoot bool) error
+	traverseNode = func(n *genericNode[T], depth int, isRoot bool) error {
+		if n.n > 2*b.t-1 || (!isRoot && n.n < b.t-1) {
+			return fmt.Errorf("One of the nodes has invalid n: %d", n.n)
+		}
+		for i := 0; i <= n.n; i++ {
+			if !n.isLeaf {
+				if err := traverseNode(n.children[i], depth+1, false); err != nil {
+					return err
+				}
+			}
+			if i < n.n {
+				items = append(items, n.items[i])
+			}
+		}
+		if n.isLeaf {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if leafDepth != depth {
+				return fmt.Errorf("one of the leaf nodes does not have the same height as the rest: %d vs %d", depth, leafDepth)
+			}
+		}
+		return nil
+	}
+	if err := traverseNode(b.root, 0, true); err != nil {
+		return err
+	}
+	for i := 1; i < len(items); i++ {
+		if b.cmp(items[i-1], items[i]) != lessThan {
+			return fmt.Errorf("btree items not in sorted order (ascending)\n: %v comes before %v", items[i-1], items[i])
+		}
+	}
+	if len(items) != expectedLen || b.len != expectedLen {
+		return fmt.Errorf("Expected btree to have len %d, instead has len %d and %d items", expectedLen, b.len, len(items))
+	}
+	return nil
+}
+
+func TestSet(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.Panics(t, func() {
+		NewSet(1, compareInts)
+	})
+
+	s := NewSet(T, compareInts)
+	_, ok := s.Min()
+	require.False(t, ok, testInfo)
+	_, ok = s.Max()
+	require.False(t, ok, testInfo)
+	require.False(t, s.Remove(0), testInfo)
+	require.Empty(t, s.Range(0, N), testInfo)
+
+	present := make(map[int]bool)
+	for i := 0; i < 10*N; i++ {
+		x := rand.Intn(N)
+		if rand.Intn(3) > 0 {
+			require.Equal(t, !present[x], s.Add(x), testInfo)
+			present[x] = true
+		} else {
+			require.Equal(t, present[x], s.Remove(x), testInfo)
+			delete(present, x)
+		}
+		require.Equal(t, present[x], s.Contains(x), testInfo)
+		require.NoError(t, checkGenericInvariances(s.tree, len(present)), testInfo)
+	}
+	require.Equal(t, len(present), s.Len(), testInfo)
+
+	var sorted []int
+	for x := range present {
+		sorted = append(sorted, x)
+	}
+	sort.Ints(sorted)
+	min, ok := s.Min()
+	require.True(t, ok, testInfo)
+	require.Equal(t, sorted[0], min, testInfo)
+	max, ok := s.Max()
+	require.True(t, ok, testInfo)
+	require.Equal(t, sorted[len(sorted)-1], max, testInfo)
+	for i := 0; i < 50; i++ {
+		lo, hi := rand.Intn(N+10)-5, rand.Intn(N+10)-5
+		var expected []int
+		for _, x := range sorted {
+			if lo <= x && x <= hi {
+				expected = append(expected, x)
+			}
+		}
+		require.Equal(t, expected, s.Range(lo, hi), "%s [%d, %d]", testInfo, lo, hi)
+	}
+
+	for _, x := range sorted {
+		require.True(t, s.Remove(x), testInfo)
+	}
+	require.NoError(t, checkGenericInvariances(s.tree, 0), testInfo)
+	require.True(t, s.tree.root.isLeaf, testInfo)
+}
+
+func ExampleSet() {
+	words := NewSet(2, strings.Compare)
+	for _, w := range strings.Fields("the quick brown fox jumps over the lazy dog") {
+		words.Add(w)
+	}
+	fmt.Println(words.Len(), words.Contains("fox"), words.Contains("cat"))
+	fmt.Println(words.Range("d", "p"))
+	words.Remove("fox")
+	min, _ := words.Min()
+	max, _ := words.Max()
+	fmt.Println(min, max, words.Range("d", "p"))
+	// Output:
+	// 8 true false
+	// [dog fox jumps lazy over]
+	// brown the [dog jumps lazy over]
+}
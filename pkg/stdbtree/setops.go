@@ -0,0 +1,232 @@
+package stdbtree
+
+// UnionBTree returns a new tree, with a's minimum degree and multiset mode,
+// holding every item found in a or b. Where a and b both hold equal items the
+// one from a is kept. In a multiset each item of b is matched with at most one
+// equal item of a, so the union keeps the higher of the two counts of an item;
+// if a is not a multiset, b's duplicates are dropped. Both trees are streamed
+// in order and the merged sequence is bulk loaded, so this takes O(n+m). The
+// inputs are left unchanged.
+func UnionBTree(a, b *BTree) *BTree {
+	merged := make([]Item, 0, a.len+b.len)
+	// fromB appends an item of b that no item of a matched
+	fromB := func(item Item) {
+		if !a.multi && len(merged) > 0 && a.cmp(merged[len(merged)-1], item) == equal {
+			return
+		}
+		merged = append(merged, item)
+	}
+	ia, ib := a.Iterator(), b.Iterator()
+	okA, okB := ia.Next(), ib.Next()
+	for okA || okB {
+		switch {
+		case !okB:
+			merged = append(merged, ia.Item())
+			okA = ia.Next()
+		case !okA:
+			fromB(ib.Item())
+			okB = ib.Next()
+		default:
+			switch a.cmp(ia.Item(), ib.Item()) {
+			case lessThan:
+				merged = append(merged, ia.Item())
+				okA = ia.Next()
+			case greaterThan:
+				fromB(ib.Item())
+				okB = ib.Next()
+			default:
+				// a wins ties
+				merged = append(merged, ia.Item())
+				okA, okB = ia.Next(), ib.Next()
+			}
+		}
+	}
+	return fromMerged(a.t, a.cmp, a.multi, merged)
+}
+
+// Absorb adds every item of other to b, the in-place sibling of UnionBTree:
+// where both hold equal items the one already in b is kept, and other is left
+// unchanged. Both trees must order items the same way. The smaller side is the
+// one inserted item by item, taking O(m log(n+m)) for m items into n: if other
+// is the larger and has the same minimum degree and multiset mode b's items
+// are set aside, the tree takes on a copy of other's nodes and b's own items
+// then go back in, winning ties.
+func (b *BTree) Absorb(other *BTree) {
+	if other == b {
+		return
+	}
+	if other.len > b.len && other.t == b.t && other.multi == b.multi {
+		own := b.ToSlice()
+		b.mods++
+		b.root = other.root.clone(b.gen)
+		b.len = other.len
+		b.rebuildBloom()
+		for _, item := range own {
+			b.Insert(item)
+		}
+		return
+	}
+	other.ForEach(func(item Item) bool {
+		if b.multi {
+			b.Insert(item)
+		} else {
+			b.GetOrInsert(item)
+		}
+		return true
+	})
+}
+
+// IntersectBTree returns a new tree, with a's minimum degree and multiset
+// mode, holding the items of a that have an equal item in b. Each item of b
+// is matched with at most one of a, so a multiset keeps the lower of the two
+// counts of an item. It merge-walks both trees in O(n+m).
+func IntersectBTree(a, b *BTree) *BTree {
+	var merged []Item
+	ia, ib := a.Iterator(), b.Iterator()
+	okA, okB := ia.Next(), ib.Next()
+	for okA && okB {
+		switch a.cmp(ia.Item(), ib.Item()) {
+		case lessThan:
+			okA = ia.Next()
+		case greaterThan:
+			okB = ib.Next()
+		default:
+			merged = append(merged, ia.Item())
+			okA, okB = ia.Next(), ib.Next()
+		}
+	}
+	return fromMerged(a.t, a.cmp, a.multi, merged)
+}
+
+// DifferenceBTree returns a new tree, with a's minimum degree and multiset
+// mode, holding the items of a that have no equal item in b. Each item of b
+// cancels out at most one of a, so a multiset keeps as many of an item as a
+// has beyond b's count. It merge-walks both trees in O(n+m).
+func DifferenceBTree(a, b *BTree) *BTree {
+	var merged []Item
+	ia, ib := a.Iterator(), b.Iterator()
+	okA, okB := ia.Next(), ib.Next()
+	for okA {
+		if !okB {
+			merged = append(merged, ia.Item())
+			okA = ia.Next()
+			continue
+		}
+		switch a.cmp(ia.Item(), ib.Item()) {
+		case lessThan:
+			merged = append(merged, ia.Item())
+			okA = ia.Next()
+		case greaterThan:
+			okB = ib.Next()
+		default:
+			okA, okB = ia.Next(), ib.Next()
+		}
+	}
+	return fromMerged(a.t, a.cmp, a.multi, merged)
+}
+
+// DiffBTree returns the changes that turn prev into next: added holds the
+// items of next with no equal item in prev and removed the items of prev with
+// no equal item in next, both in ascending order. Items found in both are in
+// neither list, even if the two differ beyond what cmp looks at. It
+// merge-walks both trees in O(n+m) using prev's order.
+func DiffBTree(prev, next *BTree) (added, removed []Item) {
+	ip, in := prev.Iterator(), next.Iterator()
+	okP, okN := ip.Next(), in.Next()
+	for okP || okN {
+		switch {
+		case !okN:
+			removed = append(removed, ip.Item())
+			okP = ip.Next()
+		case !okP:
+			added = append(added, in.Item())
+			okN = in.Next()
+		default:
+			switch prev.cmp(ip.Item(), in.Item()) {
+			case lessThan:
+				removed = append(removed, ip.Item())
+				okP = ip.Next()
+			case greaterThan:
+				added = append(added, in.Item())
+				okN = in.Next()
+			default:
+				okP, okN = ip.Next(), in.Next()
+			}
+		}
+	}
+	return added, removed
+}
+
+// MergeJoin calls onMatch(x, y) for every pair of an item x of a and an item
+// y of b that compare equal, in ascending order. Both trees are walked in
+// lockstep in O(n+m), plus the number of pairs when multisets hold runs of
+// equal items, each of which is matched against every equal item of the
+// other tree.
+func MergeJoin(a, b *BTree, onMatch func(x, y Item)) {
+	ia, ib := a.Iterator(), b.Iterator()
+	okA, okB := ia.Next(), ib.Next()
+	var run []Item
+	for okA && okB {
+		switch a.cmp(ia.Item(), ib.Item()) {
+		case lessThan:
+			okA = ia.Next()
+		case greaterThan:
+			okB = ib.Next()
+		default:
+			// gather b's run of items equal to the key, then pair it with
+			// each of a's
+			key := ib.Item()
+			run = run[:0]
+			for okB && a.cmp(key, ib.Item()) == equal {
+				run = append(run, ib.Item())
+				okB = ib.Next()
+			}
+			for okA && a.cmp(ia.Item(), key) == equal {
+				for _, y := range run {
+					onMatch(ia.Item(), y)
+				}
+				okA = ia.Next()
+			}
+		}
+	}
+}
+
+// Equals reports whether b and other hold the same items, in the sense that
+// their in-order sequences pairwise compare equal under b's comparator. The
+// minimum degrees and node shapes of the two trees do not matter. It stops at
+// the first difference and does no work at all if the lengths differ.
+func (b *BTree) Equals(other *BTree) bool {
+	if b.len != other.len {
+		return false
+	}
+	ib, io := b.Iterator(), other.Iterator()
+	for ib.Next() {
+		io.Next()
+		if b.cmp(ib.Item(), io.Item()) != equal {
+			return false
+		}
+	}
+	return true
+}
+
+// Split partitions the tree at key into two new trees with the same minimum
+// degree: left holds the items less than key and right those greater than or
+// equal to it, so an item equal to key ends up in right. The items are dumped
+// in order and each half bulk loaded, taking O(n). b itself is left unchanged.
+func (b *BTree) Split(key Item) (left, right *BTree) {
+	items := b.ToSlice()
+	k := b.Rank(key)
+	return fromMerged(b.t, b.cmp, b.multi, items[:k]), fromMerged(b.t, b.cmp, b.multi, items[k:])
+}
+
+// fromMerged bulk loads a tree from the sorted output of a merge-walk, which
+// may only hold equal items side by side if multi is set.
+func fromMerged(t int, cmp func(a, b Item) int, multi bool, items []Item) *BTree {
+	return &BTree{
+		root:  buildFromSorted(t, nil, items, 1),
+		t:     t,
+		len:   len(items),
+		cmp:   cmp,
+		multi: multi,
+	}
+}
@@ -0,0 +1,375 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// randomSet returns a tree holding a random subset of [0, n) along with the
+// set itself
+func randomSet(t, n int) (*BTree, map[int]bool) {
+	b := NewBTree(t)
+	set := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		if rand.Intn(2) == 0 {
+			b.Insert(&idItem{key: i})
+			set[i] = true
+		}
+	}
+	return b, set
+}
+
+// randomMultiset returns a multiset tree holding each of [0, n) between 0 and
+// 3 times along with the counts
+func randomMultiset(t, n int) (*BTree, map[int]int) {
+	b := NewMultiBTree(t)
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		for c := rand.Intn(4); c > 0; c-- {
+			b.Insert(numItem(i))
+			counts[i]++
+		}
+	}
+	return b, counts
+}
+
+func TestUnionBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	a, setA := randomSet(T, N)
+	b, setB := randomSet(rand.Intn(19)+2, N)
+	u := UnionBTree(a, b)
+
+	var want int
+	for i := 0; i < N; i++ {
+		if setA[i] || setB[i] {
+			want++
+		}
+	}
+	require.NoError(t, checkInvariances(u, want), testInfo)
+	for i := 0; i < N; i++ {
+		found := u.Search(&idItem{key: i})
+		require.Equal(t, setA[i] || setB[i], found != nil, testInfo)
+		// a's item wins ties
+		if setA[i] {
+			require.Same(t, a.Search(&idItem{key: i}), found, testInfo)
+		} else if setB[i] {
+			require.Same(t, b.Search(&idItem{key: i}), found, testInfo)
+		}
+	}
+
+	// union with an empty tree is a copy
+	e := NewBTree(T)
+	require.Equal(t, a.ToSlice(), UnionBTree(a, e).ToSlice(), testInfo)
+	require.Equal(t, a.ToSlice(), UnionBTree(e, a).ToSlice(), testInfo)
+	require.NoError(t, checkInvariances(UnionBTree(e, e), 0), testInfo)
+
+	// multisets keep the higher count, a set none of b's duplicates
+	ma, countA := randomMultiset(T, N)
+	mb, countB := randomMultiset(rand.Intn(19)+2, N)
+	mu := UnionBTree(ma, mb)
+	require.True(t, mu.multi, testInfo)
+	sa := NewBTree(T)
+	for i := range countA {
+		sa.Insert(numItem(i))
+	}
+	su := UnionBTree(sa, mb)
+	require.False(t, su.multi, testInfo)
+	var wantMulti, wantSet int
+	for i := 0; i < N; i++ {
+		higher := countA[i]
+		if countB[i] > higher {
+			higher = countB[i]
+		}
+		require.Equal(t, higher, mu.Count(numItem(i)), testInfo, i)
+		wantMulti += higher
+		if higher > 0 {
+			require.Equal(t, 1, su.Count(numItem(i)), testInfo, i)
+			wantSet++
+		}
+	}
+	require.NoError(t, checkInvariances(mu, wantMulti), testInfo)
+	require.NoError(t, checkInvariances(su, wantSet), testInfo)
+}
+
+func TestAbsorb(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	// either side the larger, with the same or another degree
+	for _, sizes := range [][2]int{{N, N / 10}, {N / 10, N}, {N, N}} {
+		for _, otherT := range []int{T, rand.Intn(19) + 2} {
+			info := fmt.Sprintf("%s [sizes = %v, other T = %d]", testInfo, sizes, otherT)
+			b, setB := randomSet(T, sizes[0])
+			other, setO := randomSet(otherT, sizes[1])
+			wantB := make(map[int]Item)
+			for key := range setB {
+				wantB[key] = b.Search(&idItem{key: key})
+			}
+			otherItems := other.ToSlice()
+
+			b.Absorb(other)
+			union := make(map[int]bool)
+			for key := range setB {
+				union[key] = true
+			}
+			for key := range setO {
+				union[key] = true
+			}
+			require.NoError(t, checkInvariances(b, len(union)), info)
+			for key := range union {
+				found := b.Search(&idItem{key: key})
+				if item, ok := wantB[key]; ok {
+					// b's item wins ties
+					require.Same(t, item, found, info)
+				} else {
+					require.Same(t, other.Search(&idItem{key: key}), found, info)
+				}
+			}
+
+			// other is untouched, even by later updates to b
+			for key := 0; key < N; key++ {
+				b.Delete(&idItem{key: key})
+			}
+			require.Equal(t, otherItems, other.ToSlice(), info)
+			require.NoError(t, checkInvariances(other, len(setO)), info)
+		}
+	}
+
+	// a multiset keeps both of equal items
+	m, o := NewMultiBTree(T), NewMultiBTree(T)
+	for i := 0; i < N; i++ {
+		m.Insert(numItem(i % 10))
+		o.Insert(numItem(i % 20))
+	}
+	m.Absorb(o)
+	require.NoError(t, checkInvariances(m, 2*N), testInfo)
+	require.Equal(t, N/10+N/20, m.Count(numItem(3)), testInfo)
+	m.Absorb(m)
+	require.NoError(t, checkInvariances(m, 2*N), testInfo)
+
+	// a set absorbing a larger multiset keeps one of each
+	s := NewBTree(T)
+	s.Insert(numItem(0))
+	s.Absorb(o)
+	require.NoError(t, checkInvariances(s, 20), testInfo)
+	require.Equal(t, 1, s.Count(numItem(3)), testInfo)
+}
+
+func TestIntersectDifferenceBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	for round := 0; round < 10; round++ {
+		a, setA := randomSet(T, N)
+		b, setB := randomSet(rand.Intn(19)+2, N)
+		inter, diff := IntersectBTree(a, b), DifferenceBTree(a, b)
+
+		var wantInter, wantDiff int
+		for i := range setA {
+			if setB[i] {
+				wantInter++
+			} else {
+				wantDiff++
+			}
+		}
+		require.NoError(t, checkInvariances(inter, wantInter), testInfo)
+		require.NoError(t, checkInvariances(diff, wantDiff), testInfo)
+		for i := 0; i < N; i++ {
+			key := &idItem{key: i}
+			require.Equal(t, setA[i] && setB[i], inter.Contains(key), testInfo)
+			require.Equal(t, setA[i] && !setB[i], diff.Contains(key), testInfo)
+		}
+	}
+
+	// with an empty tree
+	a, _ := randomSet(T, N)
+	e := NewBTree(T)
+	require.Equal(t, 0, IntersectBTree(a, e).Len(), testInfo)
+	require.Equal(t, 0, IntersectBTree(e, a).Len(), testInfo)
+	require.Equal(t, a.ToSlice(), DifferenceBTree(a, e).ToSlice(), testInfo)
+	require.Equal(t, 0, DifferenceBTree(e, a).Len(), testInfo)
+	require.Equal(t, 0, DifferenceBTree(a, a).Len(), testInfo)
+
+	// multisets keep the lower count, or the excess of a's
+	ma, countA := randomMultiset(T, N)
+	mb, countB := randomMultiset(rand.Intn(19)+2, N)
+	inter, diff := IntersectBTree(ma, mb), DifferenceBTree(ma, mb)
+	require.True(t, inter.multi && diff.multi, testInfo)
+	var wantInter, wantDiff int
+	for i := 0; i < N; i++ {
+		lower, excess := countA[i], countA[i]-countB[i]
+		if countB[i] < lower {
+			lower = countB[i]
+		}
+		if excess < 0 {
+			excess = 0
+		}
+		require.Equal(t, lower, inter.Count(numItem(i)), testInfo, i)
+		require.Equal(t, excess, diff.Count(numItem(i)), testInfo, i)
+		wantInter += lower
+		wantDiff += excess
+	}
+	require.NoError(t, checkInvariances(inter, wantInter), testInfo)
+	require.NoError(t, checkInvariances(diff, wantDiff), testInfo)
+	require.NoError(t, checkInvariances(IntersectBTree(ma, ma), ma.Len()), testInfo)
+}
+
+func TestDiffBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	for round := 0; round < 10; round++ {
+		prev, _ := randomSet(T, N)
+		next, _ := randomSet(rand.Intn(19)+2, N)
+		added, removed := DiffBTree(prev, next)
+		require.Equal(t, DifferenceBTree(next, prev).ToSlice(), append([]Item{}, added...), testInfo)
+		require.Equal(t, DifferenceBTree(prev, next).ToSlice(), append([]Item{}, removed...), testInfo)
+
+		// added and the items common to both make up next
+		common := IntersectBTree(next, prev)
+		require.Equal(t, next.ToSlice(), UnionBTree(fromMerged(T, next.cmp, false, added), common).ToSlice(), testInfo)
+		// and applying the diff to prev gives next
+		for _, item := range removed {
+			prev.Delete(item)
+		}
+		for _, item := range added {
+			prev.Insert(item)
+		}
+		require.True(t, prev.Equals(next), testInfo)
+	}
+
+	// with an empty tree and with itself
+	a, _ := randomSet(T, N)
+	e := NewBTree(T)
+	added, removed := DiffBTree(e, a)
+	require.Equal(t, a.ToSlice(), added, testInfo)
+	require.Empty(t, removed, testInfo)
+	added, removed = DiffBTree(a, e)
+	require.Empty(t, added, testInfo)
+	require.Equal(t, a.ToSlice(), removed, testInfo)
+	added, removed = DiffBTree(a, a.Clone())
+	require.Empty(t, added, testInfo)
+	require.Empty(t, removed, testInfo)
+}
+
+func TestSplit(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	for _, k := range []int{-1, 0, 1, 2 * N / 3, 2*N/3 + 1, 2*N - 2, 2 * N} {
+		key := numItem(k)
+		left, right := b.Split(key)
+		info := fmt.Sprintf("%s [key = %d]", testInfo, k)
+		require.NoError(t, checkInvariances(left, left.Len()), info)
+		require.NoError(t, checkInvariances(right, right.Len()), info)
+		require.Equal(t, N, left.Len()+right.Len(), info)
+		for _, item := range left.ToSlice() {
+			require.Equal(t, lessThan, item.Compare(key), info)
+		}
+		for _, item := range right.ToSlice() {
+			require.NotEqual(t, lessThan, item.Compare(key), info)
+		}
+		// the halves together hold exactly the original items
+		require.Equal(t, b.ToSlice(), append(left.ToSlice(), right.ToSlice()...), info)
+	}
+	require.NoError(t, checkInvariances(b, N), testInfo)
+}
+
+func TestEquals(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	require.True(t, NewBTree(T).Equals(NewBTree(2)), testInfo)
+
+	// same items, different insertion orders and degrees
+	a, b := NewBTree(T), NewBTree(rand.Intn(19)+2)
+	for _, i := range rand.Perm(N) {
+		a.Insert(numItem(i))
+	}
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	require.True(t, a.Equals(b), testInfo)
+	require.True(t, b.Equals(a), testInfo)
+	require.True(t, a.Equals(a), testInfo)
+
+	// differing lengths
+	b.Delete(numItem(rand.Intn(N)))
+	require.False(t, a.Equals(b), testInfo)
+	require.False(t, b.Equals(a), testInfo)
+
+	// same length, one item different
+	b.Insert(numItem(N))
+	require.False(t, a.Equals(b), testInfo)
+	require.False(t, b.Equals(a), testInfo)
+}
+
+func TestMergeJoin(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 50
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	type pair struct{ x, y Item }
+	bruteForce := func(a, b *BTree) []pair {
+		var pairs []pair
+		for _, x := range a.ToSlice() {
+			for _, y := range b.ToSlice() {
+				if x.Compare(y) == equal {
+					pairs = append(pairs, pair{x, y})
+				}
+			}
+		}
+		return pairs
+	}
+	join := func(a, b *BTree) []pair {
+		var pairs []pair
+		MergeJoin(a, b, func(x, y Item) {
+			pairs = append(pairs, pair{x, y})
+		})
+		return pairs
+	}
+
+	a, _ := randomSet(T, N)
+	b, _ := randomSet(rand.Intn(19)+2, N)
+	require.Equal(t, bruteForce(a, b), join(a, b), testInfo)
+	require.Empty(t, join(a, NewBTree(T)), testInfo)
+	require.Empty(t, join(NewBTree(T), b), testInfo)
+
+	// multisets pair up every combination of equal items
+	ma, mb := NewMultiBTree(T), NewMultiBTree(T)
+	for i := 0; i < N; i++ {
+		ma.Insert(&idItem{key: rand.Intn(10), id: i})
+		mb.Insert(&idItem{key: rand.Intn(10), id: i})
+	}
+	require.Equal(t, bruteForce(ma, mb), join(ma, mb), testInfo)
+}
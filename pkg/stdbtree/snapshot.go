@@ -0,0 +1,50 @@
+package stdbtree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SnapshotBTree publishes versions of a PersistentBTree through an atomic
+// pointer, for read-mostly use: readers Load the current version without
+// taking any lock and keep querying it as a consistent snapshot for as long as
+// they like, while a writer builds the next version off to the side and swaps
+// it in once a whole batch of updates is done. Readers never observe a batch
+// half applied. The zero value is not usable, use NewSnapshotBTree to create
+// one.
+type SnapshotBTree struct {
+	mu      sync.Mutex // serializes writers
+	current atomic.Pointer[PersistentBTree]
+}
+
+// NewSnapshotBTree creates a SnapshotBTree whose current version is an empty
+// tree with minimum degree t, see NewBTree for the constraints on t.
+func NewSnapshotBTree(t int) *SnapshotBTree {
+	s := &SnapshotBTree{}
+	s.current.Store(NewPersistentBTree(t))
+	return s
+}
+
+// Load returns the current version of the tree.
+func (s *SnapshotBTree) Load() *PersistentBTree {
+	return s.current.Load()
+}
+
+// Store publishes p as the current version of the tree.
+func (s *SnapshotBTree) Store(p *PersistentBTree) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.Store(p)
+}
+
+// Update applies a batch of updates and publishes the result: fn is handed a
+// copy-on-write clone of the current version to modify freely, and once it
+// returns the clone becomes the new current version. fn must not retain the
+// BTree. Concurrent calls to Update are applied one after the other.
+func (s *SnapshotBTree) Update(fn func(b *BTree)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.current.Load().Clone()
+	fn(b)
+	s.current.Store(&PersistentBTree{tree: b})
+}
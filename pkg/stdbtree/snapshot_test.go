@@ -0,0 +1,92 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// run with -race to check for data races
+func TestSnapshotBTree(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 1000
+	batch := 50
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	s := NewSnapshotBTree(T)
+	require.Zero(t, s.Load().Len(), testInfo)
+
+	// each batch replaces the items of the one before with the next run of
+	// batch keys, a reader seeing anything else saw a partial update
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for start := 0; start < N; start += batch {
+			s.Update(func(b *BTree) {
+				for i := start - batch; i < start; i++ {
+					b.Delete(numItem(i))
+				}
+				for i := start; i < start+batch; i++ {
+					b.Insert(numItem(i))
+				}
+			})
+		}
+	}()
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				p := s.Load()
+				if p.Len() == 0 {
+					continue
+				}
+				items := p.Clone().ToSlice()
+				if len(items) != batch {
+					t.Errorf("%s snapshot holds %d items", testInfo, len(items))
+					return
+				}
+				first := int(items[0].(numItem))
+				for i, item := range items {
+					if item != numItem(first+i) || p.Search(item) == nil {
+						t.Errorf("%s snapshot holds a partial batch: %v", testInfo, items)
+						return
+					}
+				}
+				if first%batch != 0 {
+					t.Errorf("%s snapshot batch starts at %d", testInfo, first)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the last batch is current and every snapshot taken stays as it was
+	p := s.Load()
+	require.NoError(t, checkInvariances(p.Clone(), batch), testInfo)
+	require.Equal(t, numItem(N-batch), p.Clone().Min(), testInfo)
+	s.Update(func(b *BTree) { b.Clear() })
+	require.Zero(t, s.Load().Len(), testInfo)
+	require.NoError(t, checkInvariances(p.Clone(), batch), testInfo)
+
+	// Store publishes a version built elsewhere
+	q := NewPersistentBTree(T).Insert(numItem(-1))
+	s.Store(q)
+	require.Equal(t, q, s.Load(), testInfo)
+}
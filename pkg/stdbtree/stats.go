@@ -0,0 +1,116 @@
+package stdbtree
+
+// Height returns the number of levels in the tree, 1 for a tree that is a
+// single leaf. Since all leaves are at the same depth it is enough to follow
+// the leftmost spine.
+func (b *BTree) Height() int {
+	height := 1
+	for n := b.root; !n.isLeaf; n = n.children[0] {
+		height++
+	}
+	return height
+}
+
+// SearchComparisons is like Search but also reports the no. of comparisons
+// the search makes, to study its cost empirically, e.g. against t. A node is
+// searched by binary search, so a lookup costs at most about log2(2t) per level
+// and log2(N) overall whatever t is; a linear scan of each node would instead
+// cost up to 2t-1 per level. The count covers the descent alone, skipping
+// the Bloom filter of a tree that has one. Search itself is unchanged.
+func (b *BTree) SearchComparisons(item Item) (found Item, comparisons int) {
+	count := func(x, y Item) int {
+		comparisons++
+		return b.cmp(x, y)
+	}
+	return b.root.search(count, item), comparisons
+}
+
+// BTreeStats describes the shape of a BTree, e.g. to help pick a minimum
+// degree.
+type BTreeStats struct {
+	Nodes     int
+	Leaves    int
+	Internals int
+	Items     int
+	// FillFactor is the average fraction of each node's 2t-1 item slots in
+	// use.
+	FillFactor float64
+}
+
+// Stats gathers statistics about the tree's nodes in a single traversal.
+func (b *BTree) Stats() BTreeStats {
+	var s BTreeStats
+	var traverseNode func(n *node)
+	traverseNode = func(n *node) {
+		s.Nodes++
+		s.Items += n.n
+		if n.isLeaf {
+			s.Leaves++
+			return
+		}
+		s.Internals++
+		for i := 0; i <= n.n; i++ {
+			traverseNode(n.children[i])
+		}
+	}
+	traverseNode(b.root)
+	s.FillFactor = float64(s.Items) / float64(s.Nodes*(2*b.t-1))
+	return s
+}
+
+// LevelOrder returns the items of the tree grouped by level, root first, each
+// level listing its nodes' items from left to right. It is a breadth-first
+// counterpart to a depth-first dump, handy for seeing how splits and merges
+// propagate. An empty tree has a single, empty level.
+func (b *BTree) LevelOrder() [][]Item {
+	var levels [][]Item
+	queue := []*node{b.root}
+	for len(queue) > 0 {
+		// the queue holds exactly one level at a time since all leaves are
+		// at the same depth
+		var level []Item
+		var next []*node
+		for _, n := range queue {
+			level = append(level, n.items[:n.n]...)
+			if !n.isLeaf {
+				next = append(next, n.children[:n.n+1]...)
+			}
+		}
+		levels = append(levels, level)
+		queue = next
+	}
+	return levels
+}
+
+// LevelStat describes one level of a BTree, see LevelStats.
+type LevelStat struct {
+	Level int // 1 for the root, Height for the leaves
+	Nodes int
+	Items int
+	// FillFactor is the average fraction of the 2t-1 item slots in use by
+	// the nodes at this level.
+	FillFactor float64
+}
+
+// LevelStats returns per-level statistics, root first, gathered in a single
+// breadth-first traversal. Comparing the fill of the leaves with that of the
+// levels above shows how well balanced the tree is in practice, and a low
+// fill throughout suggests running Compact.
+func (b *BTree) LevelStats() []LevelStat {
+	var stats []LevelStat
+	queue := []*node{b.root}
+	for len(queue) > 0 {
+		s := LevelStat{Level: len(stats) + 1, Nodes: len(queue)}
+		var next []*node
+		for _, n := range queue {
+			s.Items += n.n
+			if !n.isLeaf {
+				next = append(next, n.children[:n.n+1]...)
+			}
+		}
+		s.FillFactor = float64(s.Items) / float64(s.Nodes*(2*b.t-1))
+		stats = append(stats, s)
+		queue = next
+	}
+	return stats
+}
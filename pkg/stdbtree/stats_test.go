@@ -0,0 +1,173 @@
+package stdbtree
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeightStats(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, 1, b.Height(), testInfo)
+	require.Equal(t, BTreeStats{Nodes: 1, Leaves: 1}, b.Stats(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+
+	// count levels and nodes independently
+	var height int
+	levels := []*node{b.root}
+	var nodes, leaves int
+	for len(levels) > 0 {
+		height++
+		var next []*node
+		for _, n := range levels {
+			nodes++
+			if n.isLeaf {
+				leaves++
+				continue
+			}
+			next = append(next, n.children[:n.n+1]...)
+		}
+		levels = next
+	}
+	require.Equal(t, height, b.Height(), testInfo)
+
+	s := b.Stats()
+	require.Equal(t, nodes, s.Nodes, testInfo)
+	require.Equal(t, leaves, s.Leaves, testInfo)
+	require.Equal(t, nodes-leaves, s.Internals, testInfo)
+	require.Equal(t, N, s.Items, testInfo)
+	require.InDelta(t, float64(N)/float64(nodes*(2*T-1)), s.FillFactor, 1e-9, testInfo)
+	// every node but the root is at least half full
+	require.GreaterOrEqual(t, s.FillFactor, float64(T-1)/float64(2*T-1)*float64(nodes-1)/float64(nodes), testInfo)
+}
+
+func TestSearchComparisons(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	found, comparisons := b.SearchComparisons(numItem(0))
+	require.Nil(t, found, testInfo)
+	require.Equal(t, 0, comparisons, testInfo)
+
+	// a binary search over n items makes at most bits.Len(n) comparisons
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(2 * i))
+	}
+	perNode := bits.Len(uint(2*T - 1))
+	for i := -1; i < 2*N; i++ {
+		found, comparisons := b.SearchComparisons(numItem(i))
+		require.Equal(t, b.Search(numItem(i)), found, testInfo, i)
+		require.Greater(t, comparisons, 0, testInfo, i)
+		require.LessOrEqual(t, comparisons, b.Height()*perNode, testInfo, i)
+	}
+
+	// in a single leaf [1 2 3] every search probes 2 then 1 or 3
+	b = NewBTree(2)
+	for i := 1; i <= 3; i++ {
+		b.Insert(numItem(i))
+	}
+	for i := 0; i <= 4; i++ {
+		_, comparisons := b.SearchComparisons(numItem(i))
+		require.Equal(t, 2, comparisons, i)
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, [][]Item{nil}, b.LevelOrder(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	levels := b.LevelOrder()
+	require.Len(t, levels, b.Height(), testInfo)
+	require.Equal(t, b.root.items[:b.root.n], levels[0], testInfo)
+	var total int
+	for _, level := range levels {
+		// every level is sorted left to right
+		for i := 1; i < len(level); i++ {
+			require.Equal(t, lessThan, level[i-1].Compare(level[i]), testInfo)
+		}
+		total += len(level)
+	}
+	require.Equal(t, N, total, testInfo)
+
+	// a split of a full root adds a level on top holding just the median
+	b = NewBTree(2)
+	for i := 1; i <= 4; i++ {
+		b.Insert(numItem(i))
+	}
+	require.Equal(t, [][]Item{{numItem(2)}, {numItem(1), numItem(3), numItem(4)}}, b.LevelOrder())
+}
+
+func TestLevelStats(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	b := NewBTree(T)
+	require.Equal(t, []LevelStat{{Level: 1, Nodes: 1}}, b.LevelStats(), testInfo)
+
+	for _, i := range rand.Perm(N) {
+		b.Insert(numItem(i))
+	}
+	stats := b.LevelStats()
+	require.Len(t, stats, b.Height(), testInfo)
+	require.Equal(t, 1, stats[0].Nodes, testInfo)
+	var total BTreeStats
+	for i, s := range stats {
+		require.Equal(t, i+1, s.Level, testInfo)
+		require.Len(t, b.LevelOrder()[i], s.Items, testInfo)
+		require.InDelta(t, float64(s.Items)/float64(s.Nodes*(2*T-1)), s.FillFactor, 1e-9, testInfo)
+		if i > 0 {
+			// every node on the level above has n+1 children
+			require.Equal(t, stats[i-1].Items+stats[i-1].Nodes, s.Nodes, testInfo)
+		}
+		total.Nodes += s.Nodes
+		total.Items += s.Items
+	}
+	require.Equal(t, b.Stats().Nodes, total.Nodes, testInfo)
+	require.Equal(t, N, total.Items, testInfo)
+	require.Equal(t, b.Stats().Leaves, stats[len(stats)-1].Nodes, testInfo)
+}
+
+// BenchmarkSearchComparisons reports the no. of comparisons per random search
+// hit, which stays close to log2(N) across t
+func BenchmarkSearchComparisons(b *testing.B) {
+	items := benchItems()
+	benchmarkDegrees(b, func(b *testing.B, t int) {
+		tree := benchTree(t, items)
+		total := 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, comparisons := tree.SearchComparisons(items[i%benchSize])
+			total += comparisons
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "cmps/op")
+	})
+}
@@ -0,0 +1,251 @@
+package stdbtree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors Validate wraps to tell the kind of violation found, to be
+// matched with errors.Is.
+var (
+	// ErrMalformed means the nodes are not even well formed enough to be
+	// traversed, e.g. a child is missing.
+	ErrMalformed = errors.New("malformed btree")
+	// ErrDuplicate means two items compare equal in a tree that is not a
+	// multiset, under the tree's full comparison including any tiebreak, see
+	// NewBTreeWithTiebreak.
+	ErrDuplicate = errors.New("btree contains duplicate items")
+	// ErrOutOfOrder means an item comes before a smaller one.
+	ErrOutOfOrder = errors.New("btree items not in sorted order (ascending)")
+	// ErrBadNodeCount means a node holds too few or too many items.
+	ErrBadNodeCount = errors.New("btree node has invalid n")
+	// ErrBadSize means a subtree size or the tree's length does not match the
+	// no. of items actually held.
+	ErrBadSize = errors.New("btree item count is off")
+	// ErrUnequalLeafHeight means the leaves are not all at the same depth.
+	ErrUnequalLeafHeight = errors.New("leaf nodes do not all have the same height")
+	// ErrTooTall means the tree is taller than any valid B-tree holding its
+	// no. of items, see AssertHeightBound.
+	ErrTooTall = errors.New("btree is taller than its bound")
+)
+
+// Validate checks that the tree satisfies every B-tree invariant: items are
+// in ascending order without duplicates (unless the tree is a multiset), every
+// node other than the root holds between t-1 and 2t-1 items, subtree sizes and
+// the tree's length are accurate, and all leaves are at the same depth. It
+// returns an error describing the first violation found, wrapping one of the
+// Err values above, or nil.
+//
+// Validate is meant for trees that were decoded or assembled by hand, or for
+// debugging; a tree only ever modified through its methods is always valid.
+func (b *BTree) Validate() error {
+	// check that the nodes are well formed enough to be traversed at all
+	if b.root == nil {
+		return fmt.Errorf("%w: no root node", ErrMalformed)
+	}
+	if err := b.root.checkShape(); err != nil {
+		return err
+	}
+
+	var traverseItems func(n *node, fn func(i Item))
+	traverseItems = func(n *node, fn func(i Item)) {
+		var i int
+		for i = 0; i < n.n; i++ {
+			// first traverse children if internal
+			if !n.isLeaf {
+				traverseItems(n.children[i], fn)
+			}
+			fn(n.items[i])
+		}
+		// traverse last child
+		if !n.isLeaf {
+			traverseItems(n.children[i], fn)
+		}
+	}
+
+	// check that there are no duplicates and all items are in ascending order
+	// this also implictly checks that for every key k, all the items at that subtree
+	// are less than key k
+	var items []Item
+	traverseItems(b.root, func(i Item) {
+		items = append(items, i)
+	})
+	for i := 1; i < len(items); i++ {
+		switch b.cmp(items[i], items[i-1]) {
+		case equal:
+			if b.multi {
+				// multisets store equal items side by side
+				continue
+			}
+			return fmt.Errorf("%w: %v, %v", ErrDuplicate, items[i-1], items[i])
+		case lessThan:
+			return fmt.Errorf("%w\n: %v comes before %v", ErrOutOfOrder, items[i-1], items[i])
+		}
+	}
+
+	// preOrder-ish traversal, ie traverse node then children
+	var traverseNode func(n *node, fn func(n *node))
+	traverseNode = func(n *node, fn func(n *node)) {
+		fn(n)
+		if !n.isLeaf {
+			for i := 0; i < n.n+1; i++ {
+				traverseNode(n.children[i], fn)
+			}
+		}
+	}
+
+	// check every node on its own
+	var err error
+	traverseNode(b.root, func(n *node) {
+		if err == nil {
+			err = n.check(b.t, b.cmp, n == b.root)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// check that every node's size matches the no. of items in its subtree
+	traverseNode(b.root, func(n *node) {
+		var size int
+		traverseItems(n, func(Item) { size++ })
+		if n.size != size {
+			err = fmt.Errorf("%w: one of the nodes has size %d, expected %d", ErrBadSize, n.size, size)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(items) != b.len {
+		return fmt.Errorf("%w: btree has len %d but holds %d items", ErrBadSize, b.len, len(items))
+	}
+
+	// check that all leaves are at same height
+	var leafHeights []int
+	var traverseHeight func(n *node, level int)
+	traverseHeight = func(n *node, level int) {
+		if n.isLeaf {
+			leafHeights = append(leafHeights, level)
+		} else {
+			for i := 0; i <= n.n; i++ {
+				traverseHeight(n.children[i], level+1)
+			}
+		}
+	}
+	traverseHeight(b.root, 1)
+	height := leafHeights[0]
+	for _, h := range leafHeights {
+		if h != height {
+			return fmt.Errorf("%w: %d vs %d", ErrUnequalLeafHeight, h, height)
+		}
+	}
+	return nil
+}
+
+// check validates n's local invariants only, none of its descendants': that it
+// holds between t-1 and 2t-1 items (at most 2t-1 if it is the root), that its
+// items are in ascending order and that an internal node has its n+1
+// children. Equal neighbouring items pass, whether they are allowed depends on
+// the tree being a multiset, which Validate checks.
+func (n *node) check(t int, cmp func(a, b Item) int, isRoot bool) error {
+	if n.n < 0 || n.n > 2*t-1 || n.n > len(n.items) || (!isRoot && n.n < t-1) {
+		if isRoot {
+			return fmt.Errorf("%w: root node has n %d", ErrBadNodeCount, n.n)
+		}
+		return fmt.Errorf("%w: %d", ErrBadNodeCount, n.n)
+	}
+	for i := 1; i < n.n; i++ {
+		if cmp(n.items[i], n.items[i-1]) == lessThan {
+			return fmt.Errorf("%w\n: %v comes before %v", ErrOutOfOrder, n.items[i-1], n.items[i])
+		}
+	}
+	if n.isLeaf {
+		return nil
+	}
+	if len(n.children) < n.n+1 {
+		return fmt.Errorf("%w: one of the nodes has %d children for %d items", ErrMalformed, len(n.children), n.n)
+	}
+	for i := 0; i <= n.n; i++ {
+		if n.children[i] == nil {
+			return fmt.Errorf("%w: one of the nodes is missing child %d", ErrMalformed, i)
+		}
+	}
+	return nil
+}
+
+// AssertHeightBound checks the height of the tree against the CLRS bound
+// h <= log_t((n+1)/2), where h counts the edges from the root to a leaf and n
+// is the tree's len: a tree of h+1 levels holds at least 2t^h - 1 items,
+// since the root has at least one item and every other node at least t-1. It
+// returns an error wrapping ErrTooTall if the tree is taller, which only a
+// structural bug, or a wrong len, can cause. It takes O(height) time.
+func (b *BTree) AssertHeightBound() error {
+	// find the largest h with 2t^h - 1 <= n, stopping before t^h overflows
+	maxH := 0
+	for th := b.t; th <= (b.len+1)/2; th *= b.t {
+		maxH++
+		if th > (b.len+1)/2/b.t {
+			break
+		}
+	}
+	if height := b.Height(); height > maxH+1 {
+		return fmt.Errorf("%w: %d levels with %d items and t = %d, at most %d allowed", ErrTooTall, height, b.len, b.t, maxH+1)
+	}
+	return nil
+}
+
+// checkShape checks that n and its descendants can be traversed: n's item
+// count fits its items slice and an internal node has all n+1 children.
+func (n *node) checkShape() error {
+	if n.n < 0 || n.n > len(n.items) {
+		return fmt.Errorf("%w: %d", ErrBadNodeCount, n.n)
+	}
+	if n.isLeaf {
+		return nil
+	}
+	if len(n.children) < n.n+1 {
+		return fmt.Errorf("%w: one of the nodes has %d children for %d items", ErrMalformed, len(n.children), n.n)
+	}
+	for i := 0; i <= n.n; i++ {
+		if n.children[i] == nil {
+			return fmt.Errorf("%w: one of the nodes is missing child %d", ErrMalformed, i)
+		}
+		if err := n.children[i].checkShape(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild reconstructs the tree from scratch with the same minimum degree: the
+// items are extracted with an in-order walk and bulk loaded into fresh,
+// densely packed nodes. This is a recovery path for a tree that fails
+// Validate, e.g. one decoded from corrupted data; out of order items are
+// sorted and, unless the tree is a multiset, duplicates dropped (the last
+// one in order wins).
+func (b *BTree) Rebuild() {
+	var items []Item
+	var traverseItems func(n *node)
+	traverseItems = func(n *node) {
+		// be lenient about the shape since the tree may well be corrupted
+		cnt := n.n
+		if cnt > len(n.items) {
+			cnt = len(n.items)
+		}
+		for i := 0; i <= cnt; i++ {
+			if !n.isLeaf && i < len(n.children) && n.children[i] != nil {
+				traverseItems(n.children[i])
+			}
+			if i < cnt && n.items[i] != nil {
+				items = append(items, n.items[i])
+			}
+		}
+	}
+	traverseItems(b.root)
+	b.mods++
+	b.root = b.pool.newNode(b.t, true)
+	b.root.gen = b.gen
+	b.len = 0
+	b.InsertMany(items)
+	b.rebuildBloom()
+}
@@ -0,0 +1,256 @@
+package stdbtree
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRebuild(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	newTree := func() *BTree {
+		b := NewBTree(T)
+		for _, i := range rand.Perm(N) {
+			b.Insert(numItem(i))
+		}
+		require.NoError(t, b.Validate(), testInfo)
+		return b
+	}
+	// the first leaf with at least two items, with t=2 the leftmost one
+	// may hold just one
+	leaf := func(b *BTree) *node {
+		var found *node
+		var traverseNode func(n *node)
+		traverseNode = func(n *node) {
+			if found != nil {
+				return
+			}
+			if n.isLeaf {
+				if n.n >= 2 {
+					found = n
+				}
+				return
+			}
+			for i := 0; i <= n.n; i++ {
+				traverseNode(n.children[i])
+			}
+		}
+		traverseNode(b.root)
+		return found
+	}
+
+	corruptions := map[string]func(b *BTree){
+		"out of order": func(b *BTree) {
+			l := leaf(b)
+			l.items[0], l.items[1] = l.items[1], l.items[0]
+		},
+		"duplicate": func(b *BTree) {
+			l := leaf(b)
+			l.items[1] = l.items[0]
+		},
+		"wrong len": func(b *BTree) {
+			b.len++
+		},
+		"wrong size": func(b *BTree) {
+			b.root.size--
+		},
+		"underfull node": func(b *BTree) {
+			l := leaf(b)
+			l.n = 0
+		},
+		"n beyond items": func(b *BTree) {
+			b.root.n = len(b.root.items) + 1
+		},
+		"missing child": func(b *BTree) {
+			b.root.children[b.root.n] = nil
+		},
+	}
+	// each corruption is reported as its kind of violation
+	wantErrs := map[string]error{
+		"out of order":   ErrOutOfOrder,
+		"duplicate":      ErrDuplicate,
+		"wrong len":      ErrBadSize,
+		"wrong size":     ErrBadSize,
+		"underfull node": ErrBadNodeCount,
+		"n beyond items": ErrBadNodeCount,
+		"missing child":  ErrMalformed,
+	}
+	for name, corrupt := range corruptions {
+		b := newTree()
+		corrupt(b)
+		require.True(t, errors.Is(b.Validate(), wantErrs[name]), testInfo, name)
+
+		// rebuilding always yields a valid tree
+		b.Rebuild()
+		require.NoError(t, b.Validate(), testInfo, name)
+	}
+
+	// leaves at different depths
+	b := &BTree{
+		root: buildNode(2, []int{10}, buildNode(2, []int{5}), buildNode(2, []int{15}, buildNode(2, []int{12}), buildNode(2, []int{20}))),
+		t:    2,
+		len:  5,
+		cmp:  compareItems,
+	}
+	require.True(t, errors.Is(b.Validate(), ErrUnequalLeafHeight), testInfo)
+
+	// rebuilding a valid tree keeps all of its items
+	b = newTree()
+	items := b.ToSlice()
+	b.Rebuild()
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	require.Equal(t, items, b.ToSlice(), testInfo)
+
+	// out of order items are sorted back into place
+	b = newTree()
+	l := leaf(b)
+	l.items[0], l.items[1] = l.items[1], l.items[0]
+	b.Rebuild()
+	require.NoError(t, checkInvariances(b, N), testInfo)
+	require.Equal(t, items, b.ToSlice(), testInfo)
+}
+
+func TestSearchMalformed(t *testing.T) {
+	T := 3
+	malformed := map[string]*BTree{
+		"zero value": {},
+		"n beyond items": {
+			root: &node{isLeaf: true, n: 5, items: []Item{numItem(1), numItem(2)}},
+			cmp:  compareItems,
+		},
+		"negative n": {
+			root: &node{isLeaf: true, n: -1, items: []Item{numItem(1)}},
+			cmp:  compareItems,
+		},
+		"missing children": {
+			root: &node{n: 1, items: []Item{numItem(5)}},
+			cmp:  compareItems,
+		},
+		"nil child": {
+			root: buildNode(T, []int{5}, buildNode(T, []int{1}), buildNode(T, []int{9})),
+			cmp:  compareItems,
+		},
+	}
+	malformed["nil child"].root.children[1] = nil
+	for name, b := range malformed {
+		require.NotPanics(t, func() {
+			for i := 0; i <= 10; i++ {
+				b.Search(numItem(i))
+			}
+		}, name)
+		require.Error(t, b.Validate(), name)
+	}
+
+	// items reachable despite the damage are still found
+	b := malformed["nil child"]
+	require.Equal(t, numItem(5), b.Search(numItem(5)))
+	require.Equal(t, numItem(1), b.Search(numItem(1)))
+	require.Nil(t, b.Search(numItem(7)))
+}
+
+func TestNodeCheck(t *testing.T) {
+	T := 3
+	valid := map[string]*node{
+		"empty root":  buildNode(T, nil),
+		"full leaf":   buildNode(T, []int{1, 2, 3, 4, 5}),
+		"equal items": buildNode(T, []int{1, 1, 2}),
+		"internal":    buildNode(T, []int{5, 9}, buildNode(T, []int{1, 2}), buildNode(T, []int{6, 7}), buildNode(T, []int{10, 11})),
+	}
+	for name, n := range valid {
+		require.NoError(t, n.check(T, compareItems, true), name)
+	}
+	// only the root may hold fewer than t-1 items
+	require.Error(t, valid["empty root"].check(T, compareItems, false))
+	require.NoError(t, valid["full leaf"].check(T, compareItems, false))
+
+	// the order is the tree's, not the items'
+	require.Error(t, valid["full leaf"].check(T, compareItemsReverse, true))
+
+	invalid := map[string]*node{
+		"overfull":     {isLeaf: true, n: 6, items: make([]Item, 6)},
+		"out of order": buildNode(T, []int{1, 3, 2}),
+		"n beyond items": {
+			isLeaf: true, n: 3, items: []Item{numItem(1), numItem(2)},
+		},
+		"missing children": {n: 1, items: []Item{numItem(5)}},
+		"nil child":        buildNode(T, []int{5}, buildNode(T, []int{1, 2}), buildNode(T, []int{6, 7})),
+	}
+	invalid["nil child"].children[1] = nil
+	wantErrs := map[string]error{
+		"overfull":         ErrBadNodeCount,
+		"out of order":     ErrOutOfOrder,
+		"n beyond items":   ErrBadNodeCount,
+		"missing children": ErrMalformed,
+		"nil child":        ErrMalformed,
+	}
+	for name, n := range invalid {
+		require.True(t, errors.Is(n.check(T, compareItems, true), wantErrs[name]), name)
+	}
+
+	// only n itself is checked, not its descendants
+	n := buildNode(T, []int{5}, buildNode(T, []int{2, 1}), buildNode(T, []int{6, 7}))
+	require.NoError(t, n.check(T, compareItems, true))
+	require.Error(t, n.children[0].check(T, compareItems, false))
+}
+
+func TestAssertHeightBound(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	testInfo := fmt.Sprintf("[seedVal = %d]", seedVal) // for replication
+
+	// the bound is tight: the sparsest tree of each height just meets it
+	for T := 2; T <= 5; T++ {
+		for h := 0; h <= 4; h++ {
+			var build func(level int, isRoot bool) *node
+			build = func(level int, isRoot bool) *node {
+				n := newNode(T, level == h)
+				n.n = T - 1
+				if isRoot {
+					n.n = 1
+				}
+				if !n.isLeaf {
+					for i := 0; i <= n.n; i++ {
+						n.children[i] = build(level+1, false)
+					}
+				}
+				n.size = n.computeSize()
+				return n
+			}
+			b := &BTree{root: build(0, true), t: T, cmp: compareItems}
+			b.len = b.root.size
+			require.NoError(t, b.AssertHeightBound(), "T = %d, h = %d", T, h)
+			b.len--
+			if b.len > 0 {
+				require.True(t, errors.Is(b.AssertHeightBound(), ErrTooTall), "T = %d, h = %d", T, h)
+			}
+		}
+	}
+
+	// trees of random sizes and degrees, however they were built, are
+	// within it
+	for i := 0; i < 200; i++ {
+		T := rand.Intn(19) + 2
+		N := rand.Intn(2000)
+		b := NewBTree(T)
+		for _, num := range rand.Perm(2 * N) {
+			b.Insert(numItem(num))
+		}
+		for _, num := range rand.Perm(2 * N)[:N] {
+			b.Delete(numItem(num))
+		}
+		require.NoError(t, b.AssertHeightBound(), testInfo, T, N)
+
+		sparse, err := NewBTreeFromSortedFill(T, b.ToSlice(), 0.01)
+		require.NoError(t, err, testInfo)
+		require.NoError(t, sparse.AssertHeightBound(), testInfo, T, N)
+	}
+}
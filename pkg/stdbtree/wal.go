@@ -0,0 +1,104 @@
+package stdbtree
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// walOp is the kind of update a walRecord logs.
+type walOp uint8
+
+const (
+	walInsert walOp = iota + 1
+	walDelete
+)
+
+// walRecord is a single entry of a write-ahead log, exported fields again
+// being what encoding/gob requires.
+type walRecord struct {
+	Op   walOp
+	Item Item
+}
+
+// WALBTree is a BTree whose updates are appended to a write-ahead log before
+// being applied, so that after a crash ReplayWAL can rebuild it from the log.
+// Records are written in the order the updates are made, each one before its
+// update reaches the tree, so the log always covers the tree's state and a
+// failed write leaves the tree unchanged. How durable a record is once written
+// is up to the io.Writer, e.g. whether it syncs a file. A WALBTree is not safe
+// for concurrent use.
+type WALBTree struct {
+	tree *BTree
+	enc  *gob.Encoder
+}
+
+// NewBTreeWithWAL creates an empty BTree with minimum degree t that logs its
+// updates to w, which should be empty: the log is a single encoding/gob
+// stream, so a new log cannot be appended to an existing one. As with Encode
+// the concrete types of the items must be registered with gob.Register.
+func NewBTreeWithWAL(t int, w io.Writer) *WALBTree {
+	return &WALBTree{tree: NewBTree(t), enc: gob.NewEncoder(w)}
+}
+
+// Insert logs and then inserts item, see BTree.Insert. If the record cannot be
+// written the tree is left unchanged and the error returned.
+func (w *WALBTree) Insert(item Item) (prev Item, err error) {
+	if err := w.enc.Encode(walRecord{Op: walInsert, Item: item}); err != nil {
+		return nil, err
+	}
+	return w.tree.Insert(item), nil
+}
+
+// Delete logs and then deletes item, see BTree.Delete. If the record cannot be
+// written the tree is left unchanged and the error returned.
+func (w *WALBTree) Delete(item Item) (removed Item, err error) {
+	if err := w.enc.Encode(walRecord{Op: walDelete, Item: item}); err != nil {
+		return nil, err
+	}
+	return w.tree.Delete(item), nil
+}
+
+// Tree returns the underlying tree for reading. Updating it directly bypasses
+// the log, which then no longer replays to the same tree.
+func (w *WALBTree) Tree() *BTree {
+	return w.tree
+}
+
+// ReplayWAL rebuilds the tree logged to r by a WALBTree, applying every record
+// in order to a new tree with minimum degree t. Replay is deterministic as
+// long as the items' Compare is, the same log always yielding the same tree,
+// and t need not match the logged tree's for it to hold the same items. A
+// final record cut short, as by a crash in the middle of writing it, is
+// dropped; any other malformed input is an error. That includes a cut short
+// first record, which cannot be told apart from input that is not a log at
+// all.
+func ReplayWAL(r io.Reader, t int) (*BTree, error) {
+	b, err := NewBTreeChecked(t)
+	if err != nil {
+		return nil, err
+	}
+	dec := gob.NewDecoder(r)
+	for i := 0; ; i++ {
+		var rec walRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF || (i > 0 && errors.Is(err, io.ErrUnexpectedEOF)) {
+			return b, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("replaying record %d: %w", i, err)
+		}
+		if rec.Item == nil {
+			return nil, fmt.Errorf("replaying record %d: missing item", i)
+		}
+		switch rec.Op {
+		case walInsert:
+			b.Insert(rec.Item)
+		case walDelete:
+			b.Delete(rec.Item)
+		default:
+			return nil, fmt.Errorf("replaying record %d: unknown op %d", i, rec.Op)
+		}
+	}
+}
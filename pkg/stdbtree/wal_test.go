@@ -0,0 +1,72 @@
+package stdbtree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL(t *testing.T) {
+	seedVal := time.Now().UnixNano()
+	rand.Seed(seedVal)
+	N := 300
+	T := rand.Intn(19) + 2                                        // [2,20]
+	testInfo := fmt.Sprintf("[seedVal = %d, T = %d]", seedVal, T) // for replication
+
+	var log bytes.Buffer
+	w := NewBTreeWithWAL(T, &log)
+	var sizes []int // log size after each record
+	for i := 0; i < 5*N; i++ {
+		num := numItem(rand.Intn(N))
+		var err error
+		if rand.Intn(3) > 0 {
+			_, err = w.Insert(num)
+		} else {
+			_, err = w.Delete(num)
+		}
+		require.NoError(t, err, testInfo)
+		sizes = append(sizes, log.Len())
+	}
+
+	// replaying, with any degree, yields the same items
+	for _, replayT := range []int{T, rand.Intn(19) + 2} {
+		b, err := ReplayWAL(bytes.NewReader(log.Bytes()), replayT)
+		require.NoError(t, err, testInfo)
+		require.NoError(t, checkInvariances(b, w.Tree().Len()), testInfo)
+		require.Equal(t, w.Tree().ToSlice(), b.ToSlice(), testInfo)
+	}
+
+	// a crash partway through the last record loses only that record
+	cut := sizes[len(sizes)-2] + (sizes[len(sizes)-1]-sizes[len(sizes)-2])/2
+	b, err := ReplayWAL(bytes.NewReader(log.Bytes()[:cut]), T)
+	require.NoError(t, err, testInfo)
+	require.NoError(t, checkInvariances(b, b.Len()), testInfo)
+	full, _ := ReplayWAL(bytes.NewReader(log.Bytes()[:sizes[len(sizes)-2]]), T)
+	require.Equal(t, full.ToSlice(), b.ToSlice(), testInfo)
+
+	// an empty log replays to an empty tree, garbage to an error
+	b, err = ReplayWAL(bytes.NewReader(nil), T)
+	require.NoError(t, err, testInfo)
+	require.NoError(t, checkInvariances(b, 0), testInfo)
+	_, err = ReplayWAL(bytes.NewBufferString("not a log, not at all"), T)
+	require.Error(t, err, testInfo)
+	_, err = ReplayWAL(bytes.NewReader(log.Bytes()), 1)
+	require.Error(t, err, testInfo)
+
+	// a failed write leaves the tree unchanged
+	w = NewBTreeWithWAL(T, failingWriter{})
+	_, err = w.Insert(numItem(1))
+	require.Error(t, err, testInfo)
+	require.NoError(t, checkInvariances(w.Tree(), 0), testInfo)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}